@@ -0,0 +1,486 @@
+package awsenvsecretlayer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// Config holds the provider arguments needed to build the AWS session.
+type Config struct {
+	Region                 string
+	Profile                string
+	SharedConfigFiles      []string
+	SharedCredentialsFiles []string
+
+	AssumeRole                *AssumeRole
+	AssumeRoleWithWebIdentity *AssumeRoleWithWebIdentity
+
+	// Endpoints maps a service name (lambda, secretsmanager, sts) to a custom
+	// endpoint URL, e.g. a local emulator or an interface VPC endpoint
+	Endpoints                 map[string]string
+	SkipCredentialsValidation bool
+	SkipRequestingAccountID   bool
+
+	AllowedAccountIds   []string
+	ForbiddenAccountIds []string
+
+	HTTPProxy      string
+	CustomCABundle string
+	MaxRetries     int
+	RetryMode      string
+
+	DefaultLayerSettings *DefaultLayerSettings
+	ForceSecretsRefresh  bool
+}
+
+// AWSClient is the provider meta handed to resources. It creates service
+// clients that honour the configured endpoints.
+type AWSClient struct {
+	Session   *session.Session
+	Region    string
+	AccountID string
+	Partition string
+	Endpoints map[string]string
+
+	// DefaultLayerSettings are inherited by every layer, nil when the
+	// provider has no default_layer_settings block
+	DefaultLayerSettings *DefaultLayerSettings
+
+	// ForceSecretsRefresh makes layers fetch their secrets regardless of
+	// secrets_refresh_interval
+	ForceSecretsRefresh bool
+
+	// Secrets Manager clients for assumed roles, shared with the regional
+	// copies of the client so that a role is assumed once and its
+	// credentials are reused
+	secretsManagerConns *secretsManagerConnCache
+}
+
+type secretsManagerConnCache struct {
+	sync.Mutex
+	conns map[string]*secretsmanager.SecretsManager
+}
+
+// AssumeRole describes the role assumed on top of the base credentials.
+type AssumeRole struct {
+	RoleARN        string
+	SessionName    string
+	ExternalID     string
+	Duration       time.Duration
+	Tags           map[string]string
+	SourceIdentity string
+}
+
+// AssumeRoleWithWebIdentity describes the role assumed with an OIDC token
+// before any assume_role.
+type AssumeRoleWithWebIdentity struct {
+	RoleARN              string
+	SessionName          string
+	WebIdentityToken     string
+	WebIdentityTokenFile string
+	Duration             time.Duration
+}
+
+// Client builds the session, checks that credentials can be resolved and looks
+// up the account ID unless those steps are skipped.
+func (c *Config) Client() (*AWSClient, error) {
+	sess, err := c.Session()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &AWSClient{
+		Session:   sess,
+		Region:    aws.StringValue(sess.Config.Region),
+		Endpoints: c.Endpoints,
+
+		DefaultLayerSettings: c.DefaultLayerSettings,
+		ForceSecretsRefresh:  c.ForceSecretsRefresh,
+
+		secretsManagerConns: &secretsManagerConnCache{
+			conns: make(map[string]*secretsmanager.SecretsManager),
+		},
+	}
+
+	if partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), client.Region); ok {
+		client.Partition = partition.ID()
+	}
+
+	if !c.SkipCredentialsValidation {
+		if _, err := sess.Config.Credentials.Get(); err != nil {
+			return nil, fmt.Errorf("no valid credential sources found: %s", err)
+		}
+	}
+
+	if !c.SkipRequestingAccountID {
+		output, err := client.STSConn().GetCallerIdentity(&sts.GetCallerIdentityInput{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get caller identity: %s", err)
+		}
+		client.AccountID = aws.StringValue(output.Account)
+
+		if callerArn, err := arn.Parse(aws.StringValue(output.Arn)); err == nil {
+			client.Partition = callerArn.Partition
+		}
+	}
+
+	if err := c.verifyAccountID(client.AccountID); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// verifyAccountID enforces allowed_account_ids and forbidden_account_ids, so
+// that a profile mix-up cannot publish or delete layers in the wrong account.
+func (c *Config) verifyAccountID(accountID string) error {
+	if len(c.AllowedAccountIds) == 0 && len(c.ForbiddenAccountIds) == 0 {
+		return nil
+	}
+
+	if accountID == "" {
+		return fmt.Errorf("allowed_account_ids and forbidden_account_ids cannot be verified with skip_requesting_account_id enabled")
+	}
+
+	for _, forbiddenAccountID := range c.ForbiddenAccountIds {
+		if accountID == forbiddenAccountID {
+			return fmt.Errorf("AWS account ID %s is forbidden by forbidden_account_ids", accountID)
+		}
+	}
+
+	if len(c.AllowedAccountIds) == 0 {
+		return nil
+	}
+
+	for _, allowedAccountID := range c.AllowedAccountIds {
+		if accountID == allowedAccountID {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("AWS account ID %s is not allowed by allowed_account_ids", accountID)
+}
+
+// WithRegion returns a client for region that reuses the provider
+// credentials, so that a resource can override the provider region. An empty
+// region returns the client itself.
+func (c *AWSClient) WithRegion(region string) *AWSClient {
+	if region == "" || region == c.Region {
+		return c
+	}
+
+	return &AWSClient{
+		Session:             c.Session.Copy(&aws.Config{Region: aws.String(region)}),
+		Region:              region,
+		AccountID:           c.AccountID,
+		Partition:           c.Partition,
+		Endpoints:           c.Endpoints,
+		secretsManagerConns: c.secretsManagerConns,
+
+		DefaultLayerSettings: c.DefaultLayerSettings,
+		ForceSecretsRefresh:  c.ForceSecretsRefresh,
+	}
+}
+
+// LambdaConn returns a Lambda client for region, or for the provider region
+// when region is empty.
+func (c *AWSClient) LambdaConn(region string) *lambda.Lambda {
+	return lambda.New(c.Session, c.serviceConfig("lambda", region))
+}
+
+// SecretsManagerConn returns a Secrets Manager client for region, or for the
+// provider region when region is empty.
+func (c *AWSClient) SecretsManagerConn(region string) *secretsmanager.SecretsManager {
+	return secretsmanager.New(c.Session, c.serviceConfig("secretsmanager", region))
+}
+
+// SecretsManagerConnForRole returns a cached Secrets Manager client that uses
+// the credentials of roleArn, assumed with the provider credentials. Without a
+// role it is the same as SecretsManagerConn.
+func (c *AWSClient) SecretsManagerConnForRole(roleArn string, region string) (*secretsmanager.SecretsManager, error) {
+	if roleArn == "" {
+		return c.SecretsManagerConn(region), nil
+	}
+
+	c.secretsManagerConns.Lock()
+	defer c.secretsManagerConns.Unlock()
+
+	if region == "" {
+		region = c.Region
+	}
+
+	key := roleArn + "|" + region
+	if conn, ok := c.secretsManagerConns.conns[key]; ok {
+		return conn, nil
+	}
+
+	if _, err := arn.Parse(roleArn); err != nil {
+		return nil, fmt.Errorf("invalid role_arn %s: %s", roleArn, err)
+	}
+
+	assumeRole := &AssumeRole{RoleARN: roleArn}
+	sess := c.Session.Copy(&aws.Config{
+		Credentials: assumeRole.credentials(c.STSConn()),
+	})
+
+	conn := secretsmanager.New(sess, c.serviceConfig("secretsmanager", region))
+	c.secretsManagerConns.conns[key] = conn
+
+	return conn, nil
+}
+
+func (c *AWSClient) STSConn() *sts.STS {
+	return sts.New(c.Session, c.serviceConfig("sts", ""))
+}
+
+func (c *AWSClient) serviceConfig(service string, region string) *aws.Config {
+	config := &aws.Config{}
+
+	if region != "" {
+		config.Region = aws.String(region)
+	}
+
+	if endpoint := c.Endpoints[service]; endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+
+	return config
+}
+
+// Session builds the AWS session following the same credential chain as the
+// official AWS provider: static and environment credentials, shared
+// configuration (including SSO and credential_process profiles), then
+// assume_role_with_web_identity and finally assume_role.
+func (c *Config) Session() (*session.Session, error) {
+	httpClient, err := c.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := session.Options{
+		Config: aws.Config{
+			Region:     aws.String(c.Region),
+			HTTPClient: httpClient,
+		},
+		SharedConfigState: session.SharedConfigEnable,
+	}
+
+	// Throttling errors from PublishLayerVersion and GetSecretValue are
+	// retried with backoff by the SDK retryer
+	request.WithRetryer(&opts.Config, c.retryer())
+
+	if c.CustomCABundle != "" {
+		path, err := expandPath(c.CustomCABundle)
+		if err != nil {
+			return nil, err
+		}
+
+		bundle, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read custom_ca_bundle: %s", err)
+		}
+		opts.CustomCABundle = bytes.NewReader(bundle)
+	}
+
+	if c.Profile != "" {
+		opts.Profile = c.Profile
+	}
+
+	sharedConfigFiles, err := c.sharedConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+	opts.SharedConfigFiles = sharedConfigFiles
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Roles are assumed through the custom STS endpoint as well
+	stsConfig := &aws.Config{}
+	if endpoint := c.Endpoints["sts"]; endpoint != "" {
+		stsConfig.Endpoint = aws.String(endpoint)
+	}
+
+	if c.AssumeRoleWithWebIdentity != nil {
+		sess = sess.Copy(&aws.Config{
+			Credentials: c.AssumeRoleWithWebIdentity.credentials(sts.New(sess, stsConfig)),
+		})
+	}
+
+	if c.AssumeRole != nil {
+		sess = sess.Copy(&aws.Config{
+			Credentials: c.AssumeRole.credentials(sts.New(sess, stsConfig)),
+		})
+	}
+
+	return sess, nil
+}
+
+func (c *Config) httpClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if c.HTTPProxy != "" {
+		proxyURL, err := url.Parse(c.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http_proxy %q: %s", c.HTTPProxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// retryer returns the SDK retryer for max_retries and retry_mode. aws-sdk-go
+// v1 has no client-side rate limiting, so adaptive only raises the backoff
+// delays after throttling errors.
+func (c *Config) retryer() request.Retryer {
+	retryer := client.DefaultRetryer{
+		NumMaxRetries: c.MaxRetries,
+	}
+
+	if c.RetryMode == "adaptive" {
+		retryer.MinThrottleDelay = time.Second
+		retryer.MaxThrottleDelay = 20 * time.Second
+	}
+
+	return retryer
+}
+
+func (r *AssumeRole) credentials(stsSvc *sts.STS) *credentials.Credentials {
+	logger.Debug("assuming role", "role_arn", r.RoleARN, "session_name", r.SessionName)
+
+	provider := &stscreds.AssumeRoleProvider{
+		Client:   stsSvc,
+		RoleARN:  r.RoleARN,
+		Duration: r.Duration,
+	}
+
+	// Same defaults as stscreds.NewCredentials
+	provider.RoleSessionName = r.SessionName
+	if provider.RoleSessionName == "" {
+		provider.RoleSessionName = fmt.Sprintf("%d", time.Now().UTC().UnixNano())
+	}
+	if provider.Duration == 0 {
+		provider.Duration = stscreds.DefaultDuration
+	}
+
+	if r.ExternalID != "" {
+		provider.ExternalID = aws.String(r.ExternalID)
+	}
+	if r.SourceIdentity != "" {
+		provider.SourceIdentity = aws.String(r.SourceIdentity)
+	}
+	for k, v := range r.Tags {
+		provider.Tags = append(provider.Tags, &sts.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(v),
+		})
+	}
+
+	return credentials.NewCredentials(provider)
+}
+
+func (r *AssumeRoleWithWebIdentity) credentials(stsSvc *sts.STS) *credentials.Credentials {
+	logger.Debug("assuming role with web identity", "role_arn", r.RoleARN, "session_name", r.SessionName)
+
+	var tokenFetcher stscreds.TokenFetcher = stscreds.FetchTokenPath(r.WebIdentityTokenFile)
+	if r.WebIdentityToken != "" {
+		tokenFetcher = webIdentityToken(r.WebIdentityToken)
+	}
+
+	sessionName := r.SessionName
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("awsenvsecretlayer-%d", time.Now().UnixNano())
+	}
+
+	provider := stscreds.NewWebIdentityRoleProviderWithOptions(stsSvc, r.RoleARN, sessionName, tokenFetcher, func(p *stscreds.WebIdentityRoleProvider) {
+		if r.Duration > 0 {
+			p.Duration = r.Duration
+		}
+	})
+
+	return credentials.NewCredentials(provider)
+}
+
+// webIdentityToken supplies a web identity token given inline in the
+// configuration instead of through a file.
+type webIdentityToken string
+
+func (t webIdentityToken) FetchToken(ctx credentials.Context) ([]byte, error) {
+	return []byte(t), nil
+}
+
+// sharedConfigFiles lists the shared config and credentials files to load,
+// or nil to leave the SDK defaults in place. Like the SDK, config files come
+// first so that credentials files, which are loaded later, take precedence.
+// A list that is not set keeps its default file, so that setting only
+// shared_credentials_files still loads the profiles of ~/.aws/config.
+func (c *Config) sharedConfigFiles() ([]string, error) {
+	if len(c.SharedConfigFiles) == 0 && len(c.SharedCredentialsFiles) == 0 {
+		return nil, nil
+	}
+
+	configFiles := c.SharedConfigFiles
+	if len(configFiles) == 0 {
+		configFiles = []string{defaultSharedFile("AWS_CONFIG_FILE", defaults.SharedConfigFilename())}
+	}
+
+	credentialsFiles := c.SharedCredentialsFiles
+	if len(credentialsFiles) == 0 {
+		credentialsFiles = []string{defaultSharedFile("AWS_SHARED_CREDENTIALS_FILE", defaults.SharedCredentialsFilename())}
+	}
+
+	var files []string
+	for _, f := range append(append([]string{}, configFiles...), credentialsFiles...) {
+		path, err := expandPath(f)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+func defaultSharedFile(envKey string, defaultFile string) string {
+	if f := os.Getenv(envKey); f != "" {
+		return f
+	}
+
+	return defaultFile
+}
+
+func expandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to expand %s: %s", path, err)
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
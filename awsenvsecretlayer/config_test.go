@@ -0,0 +1,105 @@
+package awsenvsecretlayer
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigVerifyAccountID(t *testing.T) {
+	config := &Config{}
+	assert.NoError(t, config.verifyAccountID("111111111111"))
+	assert.NoError(t, config.verifyAccountID(""))
+
+	config = &Config{AllowedAccountIds: []string{"111111111111"}}
+	assert.NoError(t, config.verifyAccountID("111111111111"))
+	assert.Error(t, config.verifyAccountID("222222222222"))
+	assert.Error(t, config.verifyAccountID(""))
+
+	config = &Config{ForbiddenAccountIds: []string{"111111111111"}}
+	assert.Error(t, config.verifyAccountID("111111111111"))
+	assert.NoError(t, config.verifyAccountID("222222222222"))
+}
+
+func TestConfigRetryer(t *testing.T) {
+	config := &Config{MaxRetries: 25, RetryMode: "standard"}
+	assert.Equal(t, 25, config.retryer().MaxRetries())
+
+	retryer := (&Config{MaxRetries: 5, RetryMode: "adaptive"}).retryer().(client.DefaultRetryer)
+	assert.Equal(t, 5, retryer.NumMaxRetries)
+	assert.Equal(t, time.Second, retryer.MinThrottleDelay)
+}
+
+func TestConfigHTTPClient(t *testing.T) {
+	httpClient, err := (&Config{HTTPProxy: "http://proxy.example.com:3128"}).httpClient()
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://lambda.us-east-1.amazonaws.com", nil)
+	proxyURL, err := httpClient.Transport.(*http.Transport).Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "proxy.example.com:3128", proxyURL.Host)
+}
+
+func TestAWSClientWithRegion(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{Region: aws.String("us-east-1")}))
+	c := &AWSClient{Session: sess, Region: "us-east-1", AccountID: "111111111111"}
+
+	assert.Same(t, c, c.WithRegion(""))
+	assert.Same(t, c, c.WithRegion("us-east-1"))
+
+	regional := c.WithRegion("eu-west-1")
+	assert.Equal(t, "eu-west-1", regional.Region)
+	assert.Equal(t, "eu-west-1", aws.StringValue(regional.Session.Config.Region))
+	assert.Equal(t, "111111111111", regional.AccountID)
+	assert.Equal(t, "us-east-1", aws.StringValue(c.Session.Config.Region))
+}
+
+func TestConfigSessionSharedFiles(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+
+	assert.NoError(t, os.WriteFile(configFile, []byte("[profile dev]\naws_access_key_id = AKIDCONFIG\naws_secret_access_key = config\n"), 0o600))
+	assert.NoError(t, os.WriteFile(credentialsFile, []byte("[other]\naws_access_key_id = AKIDOTHER\naws_secret_access_key = other\n"), 0o600))
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+
+	// A profile from the default config file still resolves when only
+	// credentials files are given
+	sess, err := (&Config{Region: "us-east-1", Profile: "dev", SharedCredentialsFiles: []string{credentialsFile}}).Session()
+	assert.NoError(t, err)
+	creds, err := sess.Config.Credentials.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "AKIDCONFIG", creds.AccessKeyID)
+
+	// Credentials files are loaded last and take precedence
+	assert.NoError(t, os.WriteFile(credentialsFile, []byte("[dev]\naws_access_key_id = AKIDCREDENTIALS\naws_secret_access_key = credentials\n"), 0o600))
+	sess, err = (&Config{Region: "us-east-1", Profile: "dev", SharedConfigFiles: []string{configFile}, SharedCredentialsFiles: []string{credentialsFile}}).Session()
+	assert.NoError(t, err)
+	creds, err = sess.Config.Credentials.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "AKIDCREDENTIALS", creds.AccessKeyID)
+}
+
+// testAWSClient returns a client that sends every call to endpoint, such as
+// an httptest server standing in for the AWS APIs.
+func testAWSClient(region string, endpoint string) *AWSClient {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+
+	return &AWSClient{
+		Session:   sess,
+		Region:    region,
+		Endpoints: map[string]string{"lambda": endpoint, "secretsmanager": endpoint},
+	}
+}
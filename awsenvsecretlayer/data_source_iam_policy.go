@@ -0,0 +1,420 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/internal/arns"
+)
+
+var (
+	_ datasource.DataSource              = &iamPolicyDataSource{}
+	_ datasource.DataSourceWithConfigure = &iamPolicyDataSource{}
+)
+
+// iamPolicyDataSource writes the IAM policy a deploy role needs to manage a
+// layer with the given arguments, scoped to the resources the provider
+// touches.
+type iamPolicyDataSource struct {
+	client *AWSClient
+}
+
+func newIAMPolicyDataSource() datasource.DataSource {
+	return &iamPolicyDataSource{}
+}
+
+type iamPolicyDataSourceModel struct {
+	LayerName         types.String `tfsdk:"layer_name"`
+	Region            types.String `tfsdk:"region"`
+	ReplicaRegions    types.List   `tfsdk:"replica_regions"`
+	SecretsArns       types.List   `tfsdk:"secrets_arns"`
+	SecretSource      types.List   `tfsdk:"secret_source"`
+	ManagePermissions types.Bool   `tfsdk:"manage_permissions"`
+	LookupKmsKeys     types.Bool   `tfsdk:"lookup_kms_keys"`
+	PreferReplicas    types.Bool   `tfsdk:"prefer_local_secret_replicas"`
+	JSON              types.String `tfsdk:"json"`
+}
+
+func (d *iamPolicyDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_iam_policy"
+}
+
+func (d *iamPolicyDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*AWSClient)
+}
+
+func (d *iamPolicyDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"layer_name": schema.StringAttribute{
+				Required: true,
+			},
+			"region": schema.StringAttribute{
+				Optional: true,
+			},
+			"replica_regions": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"secrets_arns": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Sensitive:   true,
+				Validators: []validator.List{
+					listvalidator.ValueStringsAre(secretArnValidator{}),
+				},
+			},
+			"manage_permissions": schema.BoolAttribute{
+				Optional: true,
+			},
+			"lookup_kms_keys": schema.BoolAttribute{
+				Optional: true,
+			},
+			"prefer_local_secret_replicas": schema.BoolAttribute{
+				Optional: true,
+			},
+			"json": schema.StringAttribute{
+				Computed: true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"secret_source": schema.ListNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"arn": schema.StringAttribute{
+							Required: true,
+							Validators: []validator.String{
+								secretArnValidator{},
+							},
+						},
+						"role_arn": schema.StringAttribute{
+							Optional: true,
+						},
+						"region": schema.StringAttribute{
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *iamPolicyDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data iamPolicyDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	var secretSources []secretSourceModel
+	resp.Diagnostics.Append(data.SecretSource.ElementsAs(ctx, &secretSources, true)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client := d.client.WithRegion(data.Region.ValueString())
+	sources := expandSecretSources(stringListValue(ctx, data.SecretsArns), secretSources)
+	for i := range sources {
+		sources[i].PreferLocalReplica = data.PreferReplicas.ValueBool()
+	}
+
+	resp.Diagnostics.Append(validateSecretSources(sources, client.Partition)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	input := lambdaLayerPolicyInput{
+		Partition:         client.Partition,
+		Region:            client.Region,
+		AccountID:         client.AccountID,
+		LayerName:         data.LayerName.ValueString(),
+		ReplicaRegions:    stringListValue(ctx, data.ReplicaRegions),
+		SecretSources:     sources,
+		ManagePermissions: data.ManagePermissions.ValueBool(),
+	}
+
+	if data.LookupKmsKeys.IsNull() || data.LookupKmsKeys.ValueBool() {
+		kmsKeys, secretArns, err := lookupSecretKmsKeys(client, sources)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to describe secret", err.Error())
+			return
+		}
+		input.KmsKeys = kmsKeys
+		input.SecretArns = secretArns
+	}
+
+	policy, err := json.MarshalIndent(lambdaLayerIAMPolicy(input), "", "  ")
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to encode the policy", err.Error())
+		return
+	}
+
+	data.JSON = types.StringValue(string(policy))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, data)...)
+}
+
+type iamPolicyDocument struct {
+	Version   string               `json:"Version"`
+	Statement []iamPolicyStatement `json:"Statement"`
+}
+
+type iamPolicyStatement struct {
+	Sid       string                         `json:"Sid"`
+	Effect    string                         `json:"Effect"`
+	Action    []string                       `json:"Action"`
+	Resource  []string                       `json:"Resource"`
+	Condition map[string]map[string][]string `json:"Condition,omitempty"`
+}
+
+// secretKmsKey is the KMS key of a secret in one region, as DescribeSecret
+// reports it: a key ARN, a key ID or an alias.
+type secretKmsKey struct {
+	Secret arns.Secret
+	KeyID  string
+}
+
+type lambdaLayerPolicyInput struct {
+	Partition         string
+	Region            string
+	AccountID         string
+	LayerName         string
+	ReplicaRegions    []string
+	SecretSources     []secretSource
+	SecretArns        map[string]string
+	KmsKeys           []secretKmsKey
+	ManagePermissions bool
+}
+
+// lookupSecretKmsKeys finds the customer managed keys of the secrets read
+// with the provider credentials, in the secret's region and, for sources
+// that prefer a local replica, in the layer region. Secrets read through a
+// role are decrypted by that role. It also returns the full ARN of each of
+// those secrets by configured ARN, which may be partial.
+func lookupSecretKmsKeys(client *AWSClient, sources []secretSource) ([]secretKmsKey, map[string]string, error) {
+	var kmsKeys []secretKmsKey
+	secretArns := make(map[string]string)
+
+	for _, source := range sources {
+		if source.RoleArn != "" {
+			continue
+		}
+
+		secret, err := arns.ParseSecret(source.Arn)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		region := source.Region
+		if region == "" {
+			region = secret.Region
+		}
+
+		output, err := client.SecretsManagerConn(region).DescribeSecret(&secretsmanager.DescribeSecretInput{
+			SecretId: aws.String(source.Arn),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", source.Arn, err)
+		}
+		if output.ARN != nil {
+			secretArns[source.Arn] = aws.StringValue(output.ARN)
+		}
+
+		if keyID := aws.StringValue(output.KmsKeyId); isCustomerManagedKey(keyID) {
+			kmsKeys = append(kmsKeys, secretKmsKey{Secret: secret, KeyID: keyID})
+		}
+
+		for _, replica := range output.ReplicationStatus {
+			if !source.PreferLocalReplica || source.Region != "" || aws.StringValue(replica.Region) != client.Region {
+				continue
+			}
+			if keyID := aws.StringValue(replica.KmsKeyId); isCustomerManagedKey(keyID) {
+				kmsKeys = append(kmsKeys, secretKmsKey{Secret: secret.InRegion(client.Region), KeyID: keyID})
+			}
+		}
+	}
+
+	return kmsKeys, secretArns, nil
+}
+
+// secretPolicyResource returns the policy resource for a secret ARN. A partial
+// ARN does not match the secret it names, so the random suffix Secrets
+// Manager appends is matched with wildcards.
+func secretPolicyResource(secretArn string) string {
+	if secret, err := arns.ParseSecret(secretArn); err == nil && secret.IsPartial() {
+		return secretArn + "-??????"
+	}
+
+	return secretArn
+}
+
+// lambdaLayerIAMPolicy lists the calls the provider makes for a layer.
+// ListLayerVersions does not support resource-level permissions, so it is
+// the one statement on "*".
+func lambdaLayerIAMPolicy(in lambdaLayerPolicyInput) iamPolicyDocument {
+	accountID := in.AccountID
+	if accountID == "" {
+		accountID = "*"
+	}
+
+	var secretArns, roleArns, viaServices []string
+	for _, source := range in.SecretSources {
+		if source.RoleArn != "" {
+			roleArns = appendUnique(roleArns, source.RoleArn)
+			continue
+		}
+
+		secretArn := source.Arn
+		if describedArn, ok := in.SecretArns[source.Arn]; ok {
+			secretArn = describedArn
+		}
+
+		secretArns = appendUnique(secretArns, secretPolicyResource(secretArn))
+		if replicaID, ok := localReplicaSecretID(secretArn, in.Region); ok && source.PreferLocalReplica && source.Region == "" {
+			secretArns = appendUnique(secretArns, secretPolicyResource(replicaID))
+		}
+	}
+
+	var keyArns []string
+	var aliasKeyArns, aliases []string
+	for _, key := range in.KmsKeys {
+		viaServices = appendUnique(viaServices, fmt.Sprintf("secretsmanager.%s.amazonaws.com", key.Secret.Region))
+
+		keyArn, alias := kmsKeyResource(key)
+		if alias == "" {
+			keyArns = appendUnique(keyArns, keyArn)
+			continue
+		}
+		aliasKeyArns = appendUnique(aliasKeyArns, keyArn)
+		aliases = appendUnique(aliases, alias)
+	}
+
+	var layerArns, layerVersionArns []string
+	for _, region := range append([]string{in.Region}, in.ReplicaRegions...) {
+		layer := arns.Layer{Partition: in.Partition, Region: region, AccountID: accountID, Name: in.LayerName}
+		layerArns = appendUnique(layerArns, layer.String())
+		layerVersionArns = appendUnique(layerVersionArns, layer.String()+":*")
+	}
+
+	var statements []iamPolicyStatement
+
+	if len(secretArns) > 0 {
+		statements = append(statements, iamPolicyStatement{
+			Sid:      "ReadSecrets",
+			Action:   []string{"secretsmanager:DescribeSecret", "secretsmanager:GetSecretValue"},
+			Resource: secretArns,
+		})
+	}
+
+	if len(keyArns) > 0 {
+		statements = append(statements, iamPolicyStatement{
+			Sid:       "DecryptSecrets",
+			Action:    []string{"kms:Decrypt"},
+			Resource:  keyArns,
+			Condition: map[string]map[string][]string{"StringEquals": {"kms:ViaService": viaServices}},
+		})
+	}
+
+	if len(aliases) > 0 {
+		statements = append(statements, iamPolicyStatement{
+			Sid:      "DecryptSecretsByAlias",
+			Action:   []string{"kms:Decrypt"},
+			Resource: aliasKeyArns,
+			Condition: map[string]map[string][]string{
+				"StringEquals":             {"kms:ViaService": viaServices},
+				"ForAnyValue:StringEquals": {"kms:ResourceAliases": aliases},
+			},
+		})
+	}
+
+	if len(roleArns) > 0 {
+		statements = append(statements, iamPolicyStatement{
+			Sid:      "AssumeSecretRoles",
+			Action:   []string{"sts:AssumeRole"},
+			Resource: roleArns,
+		})
+	}
+
+	statements = append(statements,
+		iamPolicyStatement{
+			Sid:      "PublishLayer",
+			Action:   []string{"lambda:PublishLayerVersion"},
+			Resource: layerArns,
+		},
+		iamPolicyStatement{
+			Sid:      "DeleteLayerVersions",
+			Action:   []string{"lambda:DeleteLayerVersion"},
+			Resource: layerVersionArns,
+		},
+		iamPolicyStatement{
+			Sid:      "ListLayerVersions",
+			Action:   []string{"lambda:ListLayerVersions"},
+			Resource: []string{"*"},
+		},
+	)
+
+	if in.ManagePermissions {
+		statements = append(statements, iamPolicyStatement{
+			Sid:      "ManageLayerVersionPermissions",
+			Action:   []string{"lambda:AddLayerVersionPermission", "lambda:RemoveLayerVersionPermission"},
+			Resource: layerVersionArns,
+		})
+	}
+
+	for i := range statements {
+		statements[i].Effect = "Allow"
+		slices.Sort(statements[i].Resource)
+	}
+
+	return iamPolicyDocument{
+		Version:   "2012-10-17",
+		Statement: statements,
+	}
+}
+
+// isCustomerManagedKey reports whether a secret is encrypted with a key other
+// than aws/secretsmanager, which needs no grant of its own.
+func isCustomerManagedKey(keyID string) bool {
+	return keyID != "" && !strings.HasSuffix(keyID, "alias/aws/secretsmanager")
+}
+
+// kmsKeyResource returns the key ARN to grant Decrypt on, and the alias name
+// when the secret names its key by alias, since an alias ARN cannot be used
+// as a resource for Decrypt.
+func kmsKeyResource(key secretKmsKey) (string, string) {
+	keyID := key.KeyID
+	prefix := fmt.Sprintf("arn:%s:kms:%s:%s:", key.Secret.Partition, key.Secret.Region, key.Secret.AccountID)
+
+	if strings.HasPrefix(keyID, "arn:") {
+		if i := strings.Index(keyID, ":alias/"); i != -1 {
+			return keyID[:i] + ":key/*", keyID[i+1:]
+		}
+		return keyID, ""
+	}
+
+	if strings.HasPrefix(keyID, "alias/") {
+		return prefix + "key/*", keyID
+	}
+
+	return prefix + "key/" + keyID, ""
+}
+
+func appendUnique(s []string, v string) []string {
+	if slices.Contains(s, v) {
+		return s
+	}
+
+	return append(s, v)
+}
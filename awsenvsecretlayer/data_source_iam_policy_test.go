@@ -0,0 +1,109 @@
+package awsenvsecretlayer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/internal/arns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLambdaLayerIAMPolicy(t *testing.T) {
+	secret := arns.Secret{Partition: "aws", Region: "us-west-2", AccountID: "111111111111", Name: "db-AbCdEf"}
+
+	policy := lambdaLayerIAMPolicy(lambdaLayerPolicyInput{
+		Partition:      "aws",
+		Region:         "us-east-1",
+		AccountID:      "111111111111",
+		LayerName:      "example",
+		ReplicaRegions: []string{"eu-west-1"},
+		SecretSources: []secretSource{
+			{Arn: secret.String(), PreferLocalReplica: true},
+			{Arn: "arn:aws:secretsmanager:us-east-1:222222222222:secret:shared-AbCdEf", RoleArn: "arn:aws:iam::222222222222:role/reader"},
+		},
+		KmsKeys: []secretKmsKey{
+			{Secret: secret, KeyID: "1234abcd-12ab-34cd-56ef-1234567890ab"},
+		},
+		ManagePermissions: true,
+	})
+
+	statements := make(map[string]iamPolicyStatement)
+	for _, statement := range policy.Statement {
+		assert.Equal(t, "Allow", statement.Effect)
+		statements[statement.Sid] = statement
+	}
+
+	assert.Equal(t, []string{
+		"arn:aws:secretsmanager:us-east-1:111111111111:secret:db-AbCdEf",
+		"arn:aws:secretsmanager:us-west-2:111111111111:secret:db-AbCdEf",
+	}, statements["ReadSecrets"].Resource)
+	assert.Equal(t, []string{"arn:aws:kms:us-west-2:111111111111:key/1234abcd-12ab-34cd-56ef-1234567890ab"}, statements["DecryptSecrets"].Resource)
+	assert.Equal(t, []string{"secretsmanager.us-west-2.amazonaws.com"}, statements["DecryptSecrets"].Condition["StringEquals"]["kms:ViaService"])
+	assert.Equal(t, []string{"arn:aws:iam::222222222222:role/reader"}, statements["AssumeSecretRoles"].Resource)
+	assert.Equal(t, []string{
+		"arn:aws:lambda:eu-west-1:111111111111:layer:example",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example",
+	}, statements["PublishLayer"].Resource)
+	assert.Equal(t, []string{
+		"arn:aws:lambda:eu-west-1:111111111111:layer:example:*",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example:*",
+	}, statements["DeleteLayerVersions"].Resource)
+	assert.Equal(t, []string{"*"}, statements["ListLayerVersions"].Resource)
+	assert.Contains(t, statements, "ManageLayerVersionPermissions")
+	assert.NotContains(t, statements, "DecryptSecretsByAlias")
+
+	_, err := json.Marshal(policy)
+	assert.NoError(t, err)
+
+	// Replicas are only granted when they are read
+	policy = lambdaLayerIAMPolicy(lambdaLayerPolicyInput{
+		Partition:     "aws",
+		Region:        "us-east-1",
+		LayerName:     "example",
+		SecretSources: []secretSource{{Arn: secret.String()}},
+	})
+	assert.Equal(t, []string{"arn:aws:secretsmanager:us-west-2:111111111111:secret:db-AbCdEf"}, policy.Statement[0].Resource)
+}
+
+func TestLambdaLayerIAMPolicyPartialArns(t *testing.T) {
+	partialArn := "arn:aws:secretsmanager:us-west-2:111111111111:secret:example1/env-1/123"
+	describedArn := "arn:aws:secretsmanager:us-west-2:111111111111:secret:app/config"
+
+	policy := lambdaLayerIAMPolicy(lambdaLayerPolicyInput{
+		Partition: "aws",
+		Region:    "us-east-1",
+		LayerName: "example",
+		SecretSources: []secretSource{
+			{Arn: partialArn, PreferLocalReplica: true},
+			{Arn: describedArn},
+		},
+		SecretArns: map[string]string{describedArn: describedArn + "-XyZ123"},
+	})
+
+	// Partial ARNs match the secret through its random suffix, unless the
+	// full ARN was described
+	assert.Equal(t, []string{
+		"arn:aws:secretsmanager:us-east-1:111111111111:secret:example1/env-1/123-??????",
+		"arn:aws:secretsmanager:us-west-2:111111111111:secret:app/config-XyZ123",
+		"arn:aws:secretsmanager:us-west-2:111111111111:secret:example1/env-1/123-??????",
+	}, policy.Statement[0].Resource)
+}
+
+func TestKmsKeyResource(t *testing.T) {
+	secret := arns.Secret{Partition: "aws", Region: "us-east-1", AccountID: "111111111111", Name: "db-AbCdEf"}
+
+	keyArn, alias := kmsKeyResource(secretKmsKey{Secret: secret, KeyID: "arn:aws:kms:us-east-1:111111111111:key/abc"})
+	assert.Equal(t, "arn:aws:kms:us-east-1:111111111111:key/abc", keyArn)
+	assert.Empty(t, alias)
+
+	keyArn, alias = kmsKeyResource(secretKmsKey{Secret: secret, KeyID: "arn:aws:kms:us-east-1:111111111111:alias/app"})
+	assert.Equal(t, "arn:aws:kms:us-east-1:111111111111:key/*", keyArn)
+	assert.Equal(t, "alias/app", alias)
+
+	keyArn, alias = kmsKeyResource(secretKmsKey{Secret: secret, KeyID: "alias/app"})
+	assert.Equal(t, "arn:aws:kms:us-east-1:111111111111:key/*", keyArn)
+	assert.Equal(t, "alias/app", alias)
+
+	assert.False(t, isCustomerManagedKey("alias/aws/secretsmanager"))
+	assert.True(t, isCustomerManagedKey("abc"))
+}
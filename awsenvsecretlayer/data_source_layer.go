@@ -0,0 +1,314 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/internal/arns"
+)
+
+var (
+	_ datasource.DataSource              = &layerDataSource{}
+	_ datasource.DataSourceWithConfigure = &layerDataSource{}
+)
+
+// layerDataSource looks up a published layer version and the other versions
+// that still exist, for stacks that consume a layer without its state.
+type layerDataSource struct {
+	client *AWSClient
+}
+
+func newLayerDataSource() datasource.DataSource {
+	return &layerDataSource{}
+}
+
+type layerDataSourceModel struct {
+	LayerName               types.String `tfsdk:"layer_name"`
+	Version                 types.Int64  `tfsdk:"version"`
+	CodeSha256              types.String `tfsdk:"code_sha256"`
+	Region                  types.String `tfsdk:"region"`
+	LayerArn                types.String `tfsdk:"layer_arn"`
+	LayerID                 types.String `tfsdk:"layer_id"`
+	CodeSize                types.Int64  `tfsdk:"code_size"`
+	CreatedDate             types.String `tfsdk:"created_date"`
+	Description             types.String `tfsdk:"description"`
+	LicenseInfo             types.String `tfsdk:"license_info"`
+	CompatibleRuntimes      types.List   `tfsdk:"compatible_runtimes"`
+	CompatibleArchitectures types.List   `tfsdk:"compatible_architectures"`
+	Versions                types.List   `tfsdk:"versions"`
+}
+
+// layerVersionSummary describes one existing version of a layer as
+// ListLayerVersions lists it, which is without its archive.
+type layerVersionSummary struct {
+	Version                 int64    `tfsdk:"version"`
+	LayerID                 string   `tfsdk:"layer_id"`
+	CreatedDate             string   `tfsdk:"created_date"`
+	Description             string   `tfsdk:"description"`
+	LicenseInfo             string   `tfsdk:"license_info"`
+	CompatibleRuntimes      []string `tfsdk:"compatible_runtimes"`
+	CompatibleArchitectures []string `tfsdk:"compatible_architectures"`
+}
+
+// layerVersionDetails describes one version of a layer including its
+// archive, which only GetLayerVersion returns.
+type layerVersionDetails struct {
+	layerVersionSummary
+
+	CodeSha256 string
+	CodeSize   int64
+}
+
+var layerVersionSummaryType = types.ObjectType{
+	AttrTypes: map[string]attr.Type{
+		"version":                  types.Int64Type,
+		"layer_id":                 types.StringType,
+		"created_date":             types.StringType,
+		"description":              types.StringType,
+		"license_info":             types.StringType,
+		"compatible_runtimes":      types.ListType{ElemType: types.StringType},
+		"compatible_architectures": types.ListType{ElemType: types.StringType},
+	},
+}
+
+func (d *layerDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_layer"
+}
+
+func (d *layerDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*AWSClient)
+}
+
+func (d *layerDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"layer_name": schema.StringAttribute{
+				Required: true,
+			},
+			"version": schema.Int64Attribute{
+				Optional: true,
+				Computed: true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+					int64validator.ConflictsWith(path.MatchRoot("code_sha256")),
+				},
+			},
+			"code_sha256": schema.StringAttribute{
+				Optional: true,
+				Computed: true,
+			},
+			"region": schema.StringAttribute{
+				Optional: true,
+			},
+			"layer_arn": schema.StringAttribute{
+				Computed: true,
+			},
+			"layer_id": schema.StringAttribute{
+				Computed: true,
+			},
+			"code_size": schema.Int64Attribute{
+				Computed: true,
+			},
+			"created_date": schema.StringAttribute{
+				Computed: true,
+			},
+			"description": schema.StringAttribute{
+				Computed: true,
+			},
+			"license_info": schema.StringAttribute{
+				Computed: true,
+			},
+			"compatible_runtimes": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"compatible_architectures": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"versions": schema.ListAttribute{
+				ElementType: layerVersionSummaryType,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (d *layerDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data layerDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// A layer shared from another account is named by its ARN, which also
+	// tells the region to look in. Sharing only grants GetLayerVersion, so
+	// its versions cannot be listed and one has to be selected by number.
+	region := data.Region.ValueString()
+	sharedLayer := false
+	if layer, err := arns.ParseLayer(data.LayerName.ValueString()); err == nil {
+		if region == "" {
+			region = layer.Region
+		}
+		sharedLayer = d.client.AccountID != "" && layer.AccountID != d.client.AccountID
+	}
+	client := d.client.WithRegion(region)
+
+	if sharedLayer && data.Version.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("version"), "Missing layer version",
+			fmt.Sprintf("layer %s belongs to another account, whose versions cannot be listed, so version must be set", data.LayerName.ValueString()))
+		return
+	}
+
+	var versions []layerVersionSummary
+	if !sharedLayer {
+		var err error
+		versions, err = listLambdaLayerVersions(client, data.LayerName.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to list layer versions", err.Error())
+			return
+		}
+	}
+
+	selected, err := selectLayerVersion(versions, data.Version.ValueInt64(), data.CodeSha256.ValueString(), func(version int64) (layerVersionDetails, error) {
+		return getLambdaLayerVersion(client, data.LayerName.ValueString(), version)
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Layer version not found", fmt.Sprintf("layer %s: %s", data.LayerName.ValueString(), err))
+		return
+	}
+
+	layerVersion, err := arns.ParseLayerVersion(selected.LayerID)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid layer ID", err.Error())
+		return
+	}
+
+	compatibleRuntimes, diags := types.ListValueFrom(ctx, types.StringType, selected.CompatibleRuntimes)
+	resp.Diagnostics.Append(diags...)
+	compatibleArchitectures, diags := types.ListValueFrom(ctx, types.StringType, selected.CompatibleArchitectures)
+	resp.Diagnostics.Append(diags...)
+	if versions == nil {
+		versions = []layerVersionSummary{}
+	}
+	versionsValue, diags := types.ListValueFrom(ctx, layerVersionSummaryType, versions)
+	resp.Diagnostics.Append(diags...)
+
+	data.Version = types.Int64Value(selected.Version)
+	data.CodeSha256 = types.StringValue(selected.CodeSha256)
+	data.LayerArn = types.StringValue(layerVersion.Layer.String())
+	data.LayerID = types.StringValue(selected.LayerID)
+	data.CodeSize = types.Int64Value(selected.CodeSize)
+	data.CreatedDate = types.StringValue(selected.CreatedDate)
+	data.Description = types.StringValue(selected.Description)
+	data.LicenseInfo = types.StringValue(selected.LicenseInfo)
+	data.CompatibleRuntimes = compatibleRuntimes
+	data.CompatibleArchitectures = compatibleArchitectures
+	data.Versions = versionsValue
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, data)...)
+}
+
+// listLambdaLayerVersions lists every existing version of a layer, newest
+// first. layerName may also be a layer ARN.
+func listLambdaLayerVersions(client *AWSClient, layerName string) ([]layerVersionSummary, error) {
+	var versions []layerVersionSummary
+	err := client.LambdaConn("").ListLayerVersionsPages(&lambda.ListLayerVersionsInput{
+		LayerName: aws.String(layerName),
+	}, func(page *lambda.ListLayerVersionsOutput, lastPage bool) bool {
+		for _, item := range page.LayerVersions {
+			versions = append(versions, layerVersionSummary{
+				Version:                 aws.Int64Value(item.Version),
+				LayerID:                 aws.StringValue(item.LayerVersionArn),
+				CreatedDate:             aws.StringValue(item.CreatedDate),
+				Description:             aws.StringValue(item.Description),
+				LicenseInfo:             aws.StringValue(item.LicenseInfo),
+				CompatibleRuntimes:      aws.StringValueSlice(item.CompatibleRuntimes),
+				CompatibleArchitectures: aws.StringValueSlice(item.CompatibleArchitectures),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(versions, func(a, b layerVersionSummary) int {
+		return int(b.Version - a.Version)
+	})
+
+	return versions, nil
+}
+
+// getLambdaLayerVersion reads one version of a layer with its archive hash.
+func getLambdaLayerVersion(client *AWSClient, layerName string, version int64) (layerVersionDetails, error) {
+	output, err := client.LambdaConn("").GetLayerVersion(&lambda.GetLayerVersionInput{
+		LayerName:     aws.String(layerName),
+		VersionNumber: aws.Int64(version),
+	})
+	if err != nil {
+		return layerVersionDetails{}, fmt.Errorf("failed to get version %d: %s", version, err)
+	}
+
+	details := layerVersionDetails{
+		layerVersionSummary: layerVersionSummary{
+			Version:                 aws.Int64Value(output.Version),
+			LayerID:                 aws.StringValue(output.LayerVersionArn),
+			CreatedDate:             aws.StringValue(output.CreatedDate),
+			Description:             aws.StringValue(output.Description),
+			LicenseInfo:             aws.StringValue(output.LicenseInfo),
+			CompatibleRuntimes:      aws.StringValueSlice(output.CompatibleRuntimes),
+			CompatibleArchitectures: aws.StringValueSlice(output.CompatibleArchitectures),
+		},
+	}
+	if output.Content != nil {
+		details.CodeSha256 = aws.StringValue(output.Content.CodeSha256)
+		details.CodeSize = aws.Int64Value(output.Content.CodeSize)
+	}
+
+	return details, nil
+}
+
+// selectLayerVersion reads the requested version, the newest version with
+// the requested content hash, or else the newest version. The listing has
+// no content hashes, so versions are read newest first until one matches.
+func selectLayerVersion(versions []layerVersionSummary, version int64, codeSha256 string, getVersion func(int64) (layerVersionDetails, error)) (layerVersionDetails, error) {
+	if version != 0 {
+		return getVersion(version)
+	}
+
+	if len(versions) == 0 {
+		return layerVersionDetails{}, fmt.Errorf("no versions exist")
+	}
+
+	if codeSha256 == "" {
+		return getVersion(versions[0].Version)
+	}
+
+	for _, v := range versions {
+		details, err := getVersion(v.Version)
+		if err != nil {
+			return layerVersionDetails{}, err
+		}
+
+		if details.CodeSha256 == codeSha256 {
+			return details, nil
+		}
+	}
+
+	return layerVersionDetails{}, fmt.Errorf("no version has code_sha256 %s", codeSha256)
+}
@@ -0,0 +1,54 @@
+package awsenvsecretlayer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectLayerVersion(t *testing.T) {
+	versions := []layerVersionSummary{{Version: 3}, {Version: 2}, {Version: 1}}
+	codeSha256 := map[int64]string{3: "abc", 2: "abc", 1: "def"}
+
+	var read []int64
+	getVersion := func(version int64) (layerVersionDetails, error) {
+		read = append(read, version)
+		if _, ok := codeSha256[version]; !ok {
+			return layerVersionDetails{}, fmt.Errorf("version %d does not exist", version)
+		}
+		return layerVersionDetails{layerVersionSummary: layerVersionSummary{Version: version}, CodeSha256: codeSha256[version]}, nil
+	}
+
+	selected, err := selectLayerVersion(versions, 0, "", getVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), selected.Version)
+	assert.Equal(t, []int64{3}, read)
+
+	// A requested version is read without the listing, as for shared layers
+	read = nil
+	selected, err = selectLayerVersion(nil, 2, "", getVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), selected.Version)
+	assert.Equal(t, []int64{2}, read)
+
+	// Versions are read newest first only until the hash matches
+	read = nil
+	selected, err = selectLayerVersion(versions, 0, "abc", getVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), selected.Version)
+	assert.Equal(t, []int64{3}, read)
+
+	selected, err = selectLayerVersion(versions, 0, "def", getVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), selected.Version)
+
+	_, err = selectLayerVersion(versions, 4, "", getVersion)
+	assert.Error(t, err)
+
+	_, err = selectLayerVersion(versions, 0, "xyz", getVersion)
+	assert.Error(t, err)
+
+	_, err = selectLayerVersion(nil, 0, "", getVersion)
+	assert.Error(t, err)
+}
@@ -0,0 +1,167 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/dynamicvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ datasource.DataSource              = &renderedEnvDataSource{}
+	_ datasource.DataSourceWithConfigure = &renderedEnvDataSource{}
+)
+
+// renderedEnvDataSource renders the variables and the .env file of a layer
+// from the same arguments, without publishing it.
+type renderedEnvDataSource struct {
+	client *AWSClient
+}
+
+func newRenderedEnvDataSource() datasource.DataSource {
+	return &renderedEnvDataSource{}
+}
+
+type renderedEnvDataSourceModel struct {
+	YamlConfig    types.String  `tfsdk:"yaml_config"`
+	Config        types.Dynamic `tfsdk:"config"`
+	SecretsArns   types.List    `tfsdk:"secrets_arns"`
+	SecretSource  types.List    `tfsdk:"secret_source"`
+	EnvsMap       types.Map     `tfsdk:"envs_map"`
+	Region        types.String  `tfsdk:"region"`
+	Values        types.Map     `tfsdk:"values"`
+	Content       types.String  `tfsdk:"content"`
+	ContentSha256 types.String  `tfsdk:"content_sha256"`
+}
+
+func (d *renderedEnvDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_rendered_env"
+}
+
+func (d *renderedEnvDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*AWSClient)
+}
+
+func (d *renderedEnvDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"yaml_config": schema.StringAttribute{
+				Optional: true,
+			},
+			"config": schema.DynamicAttribute{
+				Optional: true,
+				Validators: []validator.Dynamic{
+					dynamicvalidator.ConflictsWith(path.MatchRoot("yaml_config")),
+					layerConfigValidator{},
+				},
+			},
+			"secrets_arns": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Sensitive:   true,
+				Validators: []validator.List{
+					listvalidator.ValueStringsAre(secretArnValidator{}),
+				},
+			},
+			"envs_map": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"region": schema.StringAttribute{
+				Optional: true,
+			},
+			"values": schema.MapAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"content": schema.StringAttribute{
+				Computed:  true,
+				Sensitive: true,
+			},
+			"content_sha256": schema.StringAttribute{
+				Computed: true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"secret_source": schema.ListNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"arn": schema.StringAttribute{
+							Required: true,
+							Validators: []validator.String{
+								secretArnValidator{},
+							},
+						},
+						"role_arn": schema.StringAttribute{
+							Optional: true,
+						},
+						"region": schema.StringAttribute{
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *renderedEnvDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data renderedEnvDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	var secretSources []secretSourceModel
+	resp.Diagnostics.Append(data.SecretSource.ElementsAs(ctx, &secretSources, true)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client := d.client.WithRegion(data.Region.ValueString())
+	sources := expandSecretSources(stringListValue(ctx, data.SecretsArns), secretSources)
+
+	resp.Diagnostics.Append(validateSecretSources(sources, client.Partition)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	configVars, err := layerConfigVars(ctx, data.Config, data.YamlConfig)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to process the layer configuration", err.Error())
+		return
+	}
+
+	// The provider default variables apply as they do to a layer
+	var defaultEnvsMap map[string]string
+	if client.DefaultLayerSettings != nil {
+		defaultEnvsMap = client.DefaultLayerSettings.EnvsMap
+	}
+	envsMap := mergeEnvsMap(defaultEnvsMap, stringMapValue(ctx, data.EnvsMap))
+
+	renderedVars, content, err := renderLayerEnv(client, configVars, sources, envsMap)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to fetch secrets", err.Error())
+		return
+	}
+
+	values, diags := types.MapValueFrom(ctx, types.StringType, renderedVars)
+	resp.Diagnostics.Append(diags...)
+
+	hash := sha256.Sum256([]byte(content))
+
+	data.Values = values
+	data.Content = types.StringValue(content)
+	data.ContentSha256 = types.StringValue(hex.EncodeToString(hash[:]))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, data)...)
+}
@@ -0,0 +1,49 @@
+package awsenvsecretlayer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderLayerEnv(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&input)
+
+		switch {
+		case strings.Contains(input.SecretId, "json"):
+			w.Write([]byte(`{"ARN": "` + input.SecretId + `", "Name": "json", "SecretString": "{\"DB_HOST\": \"db.internal\", \"API_KEY\": \"from-secret\"}"}`))
+		case strings.Contains(input.SecretId, "plain"):
+			w.Write([]byte(`{"ARN": "` + input.SecretId + `", "Name": "TOKEN", "SecretString": "plain-token"}`))
+		default:
+			w.Write([]byte(`{"ARN": "` + input.SecretId + `", "Name": "binary", "SecretBinary": "AAEC"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := testAWSClient("us-east-1", server.URL)
+	sources := expandSecretSources([]string{
+		"arn:aws:secretsmanager:us-east-1:111111111111:secret:json-AbCdEf",
+	}, nil)
+
+	values, content, err := renderLayerEnv(client, map[string]string{"APP_NAME": "example", "DB_HOST": "localhost"}, sources, map[string]string{"API_KEY": "override"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"APP_NAME": "example",
+		"DB_HOST":  "db.internal",
+		"API_KEY":  "override",
+	}, values)
+	assert.Equal(t, "API_KEY=from-secret\nAPP_NAME=example\nDB_HOST=db.internal\nAPI_KEY=override\n", content)
+
+	// The layer only takes JSON secrets, and so does the rendered file
+	_, _, err = renderLayerEnv(client, nil, expandSecretSources([]string{"arn:aws:secretsmanager:us-east-1:111111111111:secret:plain-AbCdEf"}, nil), nil)
+	assert.ErrorContains(t, err, "failed to unmarshal secret JSON")
+
+	_, _, err = renderLayerEnv(client, nil, expandSecretSources([]string{"arn:aws:secretsmanager:us-east-1:111111111111:secret:binary-AbCdEf"}, nil), nil)
+	assert.ErrorContains(t, err, "binary secrets are not supported")
+}
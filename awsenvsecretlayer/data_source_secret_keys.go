@@ -0,0 +1,180 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ datasource.DataSource              = &secretKeysDataSource{}
+	_ datasource.DataSourceWithConfigure = &secretKeysDataSource{}
+)
+
+// secretKeysDataSource lists the variables a secret contributes to a layer
+// without storing their values.
+type secretKeysDataSource struct {
+	client *AWSClient
+}
+
+func newSecretKeysDataSource() datasource.DataSource {
+	return &secretKeysDataSource{}
+}
+
+type secretKeysDataSourceModel struct {
+	Arn              types.String `tfsdk:"arn"`
+	RoleArn          types.String `tfsdk:"role_arn"`
+	Region           types.String `tfsdk:"region"`
+	Keys             types.List   `tfsdk:"keys"`
+	IsJSON           types.Bool   `tfsdk:"is_json"`
+	VersionID        types.String `tfsdk:"version_id"`
+	KmsKeyID         types.String `tfsdk:"kms_key_id"`
+	RotationEnabled  types.Bool   `tfsdk:"rotation_enabled"`
+	LastRotatedDate  types.String `tfsdk:"last_rotated_date"`
+	NextRotationDate types.String `tfsdk:"next_rotation_date"`
+}
+
+func (d *secretKeysDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_secret_keys"
+}
+
+func (d *secretKeysDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*AWSClient)
+}
+
+func (d *secretKeysDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"arn": schema.StringAttribute{
+				Required: true,
+				Validators: []validator.String{
+					secretArnValidator{},
+				},
+			},
+			"role_arn": schema.StringAttribute{
+				Optional: true,
+			},
+			"region": schema.StringAttribute{
+				Optional: true,
+			},
+			"keys": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"is_json": schema.BoolAttribute{
+				Computed: true,
+			},
+			"version_id": schema.StringAttribute{
+				Computed: true,
+			},
+			"kms_key_id": schema.StringAttribute{
+				Computed: true,
+			},
+			"rotation_enabled": schema.BoolAttribute{
+				Computed: true,
+			},
+			"last_rotated_date": schema.StringAttribute{
+				Computed: true,
+			},
+			"next_rotation_date": schema.StringAttribute{
+				Computed: true,
+			},
+		},
+	}
+}
+
+func (d *secretKeysDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data secretKeysDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	source := secretSource{
+		Arn:     data.Arn.ValueString(),
+		RoleArn: data.RoleArn.ValueString(),
+		Region:  data.Region.ValueString(),
+
+		Attribute: "arn",
+	}
+
+	resp.Diagnostics.Append(validateSecretSources([]secretSource{source}, d.client.Partition)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	result, err := getSecretValue(d.client, source)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to fetch secret", err.Error())
+		return
+	}
+
+	secretKeys, err := secretValueKeys(result)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to fetch secret", err.Error())
+		return
+	}
+
+	description, err := describeSecret(d.client, source)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to describe secret", err.Error())
+		return
+	}
+
+	keys, diags := types.ListValueFrom(ctx, types.StringType, secretKeys)
+	resp.Diagnostics.Append(diags...)
+
+	if !isJSON(aws.StringValue(result.SecretString)) {
+		resp.Diagnostics.AddAttributeWarning(path.Root("arn"), "Secret is not a JSON object",
+			fmt.Sprintf("secret %s is not a JSON object of string values and cannot be written to a layer", source.Arn))
+	}
+
+	data.Keys = keys
+	data.IsJSON = types.BoolValue(isJSON(aws.StringValue(result.SecretString)))
+	data.VersionID = types.StringValue(aws.StringValue(result.VersionId))
+	data.KmsKeyID = types.StringPointerValue(description.KmsKeyId)
+	data.RotationEnabled = types.BoolValue(aws.BoolValue(description.RotationEnabled))
+	data.LastRotatedDate = timeValueOrNull(description.LastRotatedDate)
+	data.NextRotationDate = timeValueOrNull(description.NextRotationDate)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, data)...)
+}
+
+// secretValueKeys returns the sorted variable names a secret contributes to
+// a layer, decoding it as the layer does. A secret that is not a JSON object
+// contributes none.
+func secretValueKeys(result *secretsmanager.GetSecretValueOutput) ([]string, error) {
+	if result.SecretString != nil && !isJSON(*result.SecretString) {
+		return []string{}, nil
+	}
+
+	secretVars, err := secretJSONVars(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Sorted(maps.Keys(secretVars)), nil
+}
+
+func timeValueOrNull(t *time.Time) types.String {
+	if t == nil {
+		return types.StringNull()
+	}
+
+	return types.StringValue(formatTime(*t))
+}
@@ -0,0 +1,47 @@
+package awsenvsecretlayer
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretValueVars(t *testing.T) {
+	vars, err := secretValueVars(&secretsmanager.GetSecretValueOutput{
+		Name:         aws.String("db/credentials"),
+		SecretString: aws.String(`{"username": "app", "password": "secret"}`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"username": "app", "password": "secret"}, vars)
+
+	vars, err = secretValueVars(&secretsmanager.GetSecretValueOutput{
+		Name:         aws.String("api-token"),
+		SecretString: aws.String("token"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"api-token": "token"}, vars)
+
+	keys, err := secretValueKeys(&secretsmanager.GetSecretValueOutput{
+		Name:         aws.String("db/credentials"),
+		SecretString: aws.String(`{"username": "app", "password": "secret"}`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"password", "username"}, keys)
+
+	// Values the layer cannot publish are rejected as the layer does
+	_, err = secretValueKeys(&secretsmanager.GetSecretValueOutput{
+		Name:         aws.String("db/config"),
+		SecretString: aws.String(`{"port": 5432, "host": "db"}`),
+	})
+	assert.ErrorContains(t, err, "failed to unmarshal secret JSON")
+
+	// and a secret that is not JSON contributes no variables
+	keys, err = secretValueKeys(&secretsmanager.GetSecretValueOutput{
+		Name:         aws.String("api-token"),
+		SecretString: aws.String("token"),
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
@@ -0,0 +1,135 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// DefaultLayerSettings holds the provider default_layer_settings block. A
+// layer inherits every setting it does not configure itself, except envs_map,
+// which is merged with the layer's own map when publishing.
+type DefaultLayerSettings struct {
+	CompatibleRuntimes      []string
+	CompatibleArchitectures []string
+	Description             string
+	LicenseInfo             string
+	LicenseFiles            []string
+	EnvsMap                 map[string]string
+	HistorySize             int
+	SkipDestroy             bool
+}
+
+func expandDefaultLayerSettings(m map[string]interface{}) *DefaultLayerSettings {
+	settings := &DefaultLayerSettings{
+		CompatibleRuntimes:      expandStringValueList(m["compatible_runtimes"].([]interface{})),
+		CompatibleArchitectures: expandStringValueList(m["compatible_architectures"].([]interface{})),
+		Description:             m["description"].(string),
+		LicenseInfo:             m["license_info"].(string),
+		LicenseFiles:            expandStringValueList(m["license_files"].([]interface{})),
+		EnvsMap:                 make(map[string]string),
+		HistorySize:             m["history_size"].(int),
+		SkipDestroy:             m["skip_destroy"].(bool),
+	}
+
+	for k, v := range m["envs_map"].(map[string]interface{}) {
+		settings.EnvsMap[k] = v.(string)
+	}
+
+	return settings
+}
+
+// mergeEnvsMap layers the configured variables over the default ones.
+func mergeEnvsMap(defaults map[string]string, configured map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(configured))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range configured {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// applyDefaultLayerSettings plans the effective value of every attribute that
+// inherits from default_layer_settings, so that the plan shows what is
+// published. A value set on the layer replaces the default, even when it is
+// empty. A configured envs_map is left as it is, since Terraform rejects a
+// plan that differs from the configuration, and the default variables are
+// merged with it in effective_envs_map instead.
+func applyDefaultLayerSettings(ctx context.Context, config lambdaLayerResourceModel, plan *lambdaLayerResourceModel, defaults *DefaultLayerSettings) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if defaults == nil {
+		defaults = &DefaultLayerSettings{}
+	}
+
+	lists := []struct {
+		config   types.List
+		plan     *types.List
+		defaults []string
+	}{
+		{config.CompatibleRuntimes, &plan.CompatibleRuntimes, defaults.CompatibleRuntimes},
+		{config.CompatibleArchitectures, &plan.CompatibleArchitectures, defaults.CompatibleArchitectures},
+		{config.LicenseFiles, &plan.LicenseFiles, defaults.LicenseFiles},
+	}
+	for _, l := range lists {
+		if !l.config.IsNull() {
+			continue
+		}
+
+		if len(l.defaults) == 0 {
+			*l.plan = types.ListNull(types.StringType)
+			continue
+		}
+
+		v, d := types.ListValueFrom(ctx, types.StringType, l.defaults)
+		diags.Append(d...)
+		*l.plan = v
+	}
+
+	if config.Description.IsNull() {
+		plan.Description = stringValueOrNull(defaults.Description)
+	}
+	if config.LicenseInfo.IsNull() {
+		plan.LicenseInfo = stringValueOrNull(defaults.LicenseInfo)
+	}
+	if config.HistorySize.IsNull() {
+		plan.HistorySize = types.Int64Value(int64(defaults.HistorySize))
+	}
+	if config.SkipDestroy.IsNull() {
+		plan.SkipDestroy = types.BoolValue(defaults.SkipDestroy)
+	}
+
+	if config.EnvsMap.IsNull() {
+		plan.EnvsMap = types.MapNull(types.StringType)
+		if len(defaults.EnvsMap) > 0 {
+			envsMap, d := types.MapValueFrom(ctx, types.StringType, defaults.EnvsMap)
+			diags.Append(d...)
+			plan.EnvsMap = envsMap
+		}
+	}
+
+	// Unknown variables may shadow a default, so the merged map is only known
+	// once they are
+	if !isFullyKnown(ctx, config.EnvsMap) {
+		plan.EffectiveEnvsMap = types.MapUnknown(types.StringType)
+		return diags
+	}
+
+	effectiveEnvsMap, d := types.MapValueFrom(ctx, types.StringType, mergeEnvsMap(defaults.EnvsMap, stringMapValue(ctx, config.EnvsMap)))
+	diags.Append(d...)
+	plan.EffectiveEnvsMap = effectiveEnvsMap
+
+	return diags
+}
+
+func stringValueOrNull(s string) types.String {
+	if s == "" {
+		return types.StringNull()
+	}
+
+	return types.StringValue(s)
+}
@@ -0,0 +1,71 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeEnvsMap(t *testing.T) {
+	defaults := map[string]string{"ORG_NAME": "acme", "LOG_FORMAT": "json"}
+
+	merged := mergeEnvsMap(defaults, map[string]string{"LOG_FORMAT": "text", "APP": "api"})
+	assert.Equal(t, map[string]string{"ORG_NAME": "acme", "LOG_FORMAT": "text", "APP": "api"}, merged)
+
+	assert.Equal(t, map[string]string{}, mergeEnvsMap(nil, map[string]string{}))
+}
+
+func TestApplyDefaultLayerSettings(t *testing.T) {
+	ctx := context.Background()
+	defaults := expandDefaultLayerSettings(map[string]interface{}{
+		"compatible_runtimes":      []interface{}{"python3.12"},
+		"compatible_architectures": []interface{}{},
+		"description":              "org",
+		"license_info":             "",
+		"license_files":            []interface{}{"LICENSE"},
+		"envs_map":                 map[string]interface{}{"ORG_NAME": "acme", "LOG_FORMAT": "json"},
+		"history_size":             3,
+		"skip_destroy":             true,
+	})
+
+	config := lambdaLayerResourceModel{
+		CompatibleRuntimes:      types.ListNull(types.StringType),
+		CompatibleArchitectures: types.ListNull(types.StringType),
+		LicenseFiles:            types.ListValueMust(types.StringType, nil),
+		Description:             types.StringValue("own"),
+		LicenseInfo:             types.StringNull(),
+		EnvsMap:                 types.MapValueMust(types.StringType, map[string]attr.Value{"ORG_NAME": types.StringValue("mine")}),
+		HistorySize:             types.Int64Null(),
+		SkipDestroy:             types.BoolValue(false),
+	}
+	plan := config
+
+	assert.False(t, applyDefaultLayerSettings(ctx, config, &plan, defaults).HasError())
+	assert.Equal(t, []string{"python3.12"}, stringListValue(ctx, plan.CompatibleRuntimes))
+	assert.True(t, plan.CompatibleArchitectures.IsNull())
+	assert.Empty(t, stringListValue(ctx, plan.LicenseFiles))
+	assert.Equal(t, "own", plan.Description.ValueString())
+	assert.True(t, plan.LicenseInfo.IsNull())
+	// A configured envs_map stays as configured, the merge is planned apart
+	assert.True(t, plan.EnvsMap.Equal(config.EnvsMap))
+	assert.Equal(t, map[string]string{"ORG_NAME": "mine", "LOG_FORMAT": "json"}, stringMapValue(ctx, plan.EffectiveEnvsMap))
+	assert.Equal(t, int64(3), plan.HistorySize.ValueInt64())
+	assert.False(t, plan.SkipDestroy.ValueBool())
+
+	// An unset envs_map is filled from the defaults
+	config.EnvsMap = types.MapNull(types.StringType)
+	plan = config
+	assert.False(t, applyDefaultLayerSettings(ctx, config, &plan, defaults).HasError())
+	assert.Equal(t, map[string]string{"ORG_NAME": "acme", "LOG_FORMAT": "json"}, stringMapValue(ctx, plan.EnvsMap))
+	assert.Equal(t, map[string]string{"ORG_NAME": "acme", "LOG_FORMAT": "json"}, stringMapValue(ctx, plan.EffectiveEnvsMap))
+
+	config.EnvsMap = types.MapUnknown(types.StringType)
+	plan = config
+	assert.False(t, applyDefaultLayerSettings(ctx, config, &plan, nil).HasError())
+	assert.True(t, plan.EnvsMap.IsUnknown())
+	assert.True(t, plan.EffectiveEnvsMap.IsUnknown())
+	assert.Equal(t, int64(0), plan.HistorySize.ValueInt64())
+}
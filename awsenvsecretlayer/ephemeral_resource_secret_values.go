@@ -0,0 +1,119 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ ephemeral.EphemeralResource              = &secretValuesEphemeralResource{}
+	_ ephemeral.EphemeralResourceWithConfigure = &secretValuesEphemeralResource{}
+)
+
+// secretValuesEphemeralResource reads secrets the way a layer does without
+// storing them in the plan or state, for write-only arguments such as
+// envs_map_wo.
+type secretValuesEphemeralResource struct {
+	client *AWSClient
+}
+
+func newSecretValuesEphemeralResource() ephemeral.EphemeralResource {
+	return &secretValuesEphemeralResource{}
+}
+
+type secretValuesEphemeralResourceModel struct {
+	SecretsArns  types.List   `tfsdk:"secrets_arns"`
+	SecretSource types.List   `tfsdk:"secret_source"`
+	Region       types.String `tfsdk:"region"`
+	Values       types.Map    `tfsdk:"values"`
+}
+
+func (e *secretValuesEphemeralResource) Metadata(ctx context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_secret_values"
+}
+
+func (e *secretValuesEphemeralResource) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	e.client = req.ProviderData.(*AWSClient)
+}
+
+func (e *secretValuesEphemeralResource) Schema(ctx context.Context, req ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"secrets_arns": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.ValueStringsAre(secretArnValidator{}),
+				},
+			},
+			"region": schema.StringAttribute{
+				Optional: true,
+			},
+			"values": schema.MapAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Sensitive:   true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"secret_source": schema.ListNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"arn": schema.StringAttribute{
+							Required: true,
+							Validators: []validator.String{
+								secretArnValidator{},
+							},
+						},
+						"role_arn": schema.StringAttribute{
+							Optional: true,
+						},
+						"region": schema.StringAttribute{
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (e *secretValuesEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	var data secretValuesEphemeralResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	var secretSources []secretSourceModel
+	resp.Diagnostics.Append(data.SecretSource.ElementsAs(ctx, &secretSources, true)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client := e.client.WithRegion(data.Region.ValueString())
+	sources := expandSecretSources(stringListValue(ctx, data.SecretsArns), secretSources)
+
+	resp.Diagnostics.Append(validateSecretSources(sources, client.Partition)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	values, err := fetchSecretValues(sources, client)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to fetch secrets", err.Error())
+		return
+	}
+
+	secretValues, diags := types.MapValueFrom(ctx, types.StringType, values)
+	resp.Diagnostics.Append(diags...)
+	data.Values = secretValues
+
+	resp.Diagnostics.Append(resp.Result.Set(ctx, data)...)
+}
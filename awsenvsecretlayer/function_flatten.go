@@ -0,0 +1,60 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ function.Function = &flattenFunction{}
+
+// flattenFunction flattens an object the way the layer flattens config and
+// yaml_config, with a configurable separator.
+type flattenFunction struct{}
+
+func newFlattenFunction() function.Function {
+	return &flattenFunction{}
+}
+
+func (f *flattenFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "flatten"
+}
+
+func (f *flattenFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Flattens an object into environment variables",
+		Description: "Joins nested keys with the separator and keeps string values, the same way a layer flattens config and yaml_config when the separator is an underscore.",
+		Parameters: []function.Parameter{
+			function.DynamicParameter{
+				Name:        "obj",
+				Description: "The object or map to flatten.",
+			},
+			function.StringParameter{
+				Name:        "sep",
+				Description: "The separator placed between nested keys.",
+			},
+		},
+		Return: function.MapReturn{
+			ElementType: types.StringType,
+		},
+	}
+}
+
+func (f *flattenFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var obj types.Dynamic
+	var sep string
+
+	resp.Error = req.Arguments.Get(ctx, &obj, &sep)
+	if resp.Error != nil {
+		return
+	}
+
+	data, err := dynamicObjectValue(ctx, obj.UnderlyingValue())
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, flattenConfigWithSeparator(data, sep))
+}
@@ -0,0 +1,48 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ function.Function = &renderDotenvFunction{}
+
+// renderDotenvFunction renders variables with the writer of the layer's .env
+// file.
+type renderDotenvFunction struct{}
+
+func newRenderDotenvFunction() function.Function {
+	return &renderDotenvFunction{}
+}
+
+func (f *renderDotenvFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "render_dotenv"
+}
+
+func (f *renderDotenvFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Renders environment variables as a .env file",
+		Description: "Writes one KEY=value line per variable, sorted by key, exactly as a layer writes its .env file.",
+		Parameters: []function.Parameter{
+			function.MapParameter{
+				Name:        "map",
+				Description: "The variables to render.",
+				ElementType: types.StringType,
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *renderDotenvFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var envsMap map[string]string
+
+	resp.Error = req.Arguments.Get(ctx, &envsMap)
+	if resp.Error != nil {
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, mapToEnvFormat(envsMap))
+}
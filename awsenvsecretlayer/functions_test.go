@@ -0,0 +1,57 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlattenFunction(t *testing.T) {
+	ctx := context.Background()
+
+	obj := types.DynamicValue(types.ObjectValueMust(
+		map[string]attr.Type{
+			"name": types.StringType,
+			"db":   types.ObjectType{AttrTypes: map[string]attr.Type{"host": types.StringType}},
+		},
+		map[string]attr.Value{
+			"name": types.StringValue("test"),
+			"db":   types.ObjectValueMust(map[string]attr.Type{"host": types.StringType}, map[string]attr.Value{"host": types.StringValue("localhost")}),
+		},
+	))
+
+	req := function.RunRequest{Arguments: function.NewArgumentsData([]attr.Value{obj, types.StringValue("__")})}
+	resp := function.RunResponse{Result: function.NewResultData(types.MapUnknown(types.StringType))}
+	newFlattenFunction().Run(ctx, req, &resp)
+	assert.Nil(t, resp.Error)
+
+	expected := types.MapValueMust(types.StringType, map[string]attr.Value{
+		"name":         types.StringValue("test"),
+		"db__db__host": types.StringValue("localhost"),
+	})
+	assert.Equal(t, expected, resp.Result.Value())
+
+	req = function.RunRequest{Arguments: function.NewArgumentsData([]attr.Value{types.DynamicValue(types.StringValue("test")), types.StringValue("_")})}
+	resp = function.RunResponse{Result: function.NewResultData(types.MapUnknown(types.StringType))}
+	newFlattenFunction().Run(ctx, req, &resp)
+	assert.NotNil(t, resp.Error)
+}
+
+func TestRenderDotenvFunction(t *testing.T) {
+	ctx := context.Background()
+
+	envsMap := types.MapValueMust(types.StringType, map[string]attr.Value{
+		"B": types.StringValue("2"),
+		"A": types.StringValue("1"),
+	})
+
+	req := function.RunRequest{Arguments: function.NewArgumentsData([]attr.Value{envsMap})}
+	resp := function.RunResponse{Result: function.NewResultData(types.StringUnknown())}
+	newRenderDotenvFunction().Run(ctx, req, &resp)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, types.StringValue("A=1\nB=2\n"), resp.Result.Value())
+}
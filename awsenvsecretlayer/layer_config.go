@@ -0,0 +1,113 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// layerConfigVars flattens the variables of config, or of yaml_config when
+// config is not set, so that both produce the same .env content for the same
+// data.
+func layerConfigVars(ctx context.Context, config types.Dynamic, yamlConfig types.String) (map[string]string, error) {
+	if config.IsNull() || config.IsUnderlyingValueNull() {
+		return processYamlConfig(yamlConfig.ValueString())
+	}
+
+	data, err := dynamicObjectValue(ctx, config.UnderlyingValue())
+	if err != nil {
+		return nil, err
+	}
+
+	return flattenConfig(data), nil
+}
+
+// dynamicObjectValue converts an object or map into the shape that
+// yaml.Unmarshal produces for the same data, so that it can be flattened by
+// the same rules.
+func dynamicObjectValue(ctx context.Context, v attr.Value) (map[string]interface{}, error) {
+	tfValue, err := v.ToTerraformValue(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := terraformValueToInterface(tfValue)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value must be an object or a map, got %s", tfValue.Type())
+	}
+
+	return data, nil
+}
+
+func terraformValueToInterface(v tftypes.Value) (interface{}, error) {
+	if !v.IsKnown() {
+		return nil, fmt.Errorf("value is not known")
+	}
+	if v.IsNull() {
+		return nil, nil
+	}
+
+	switch v.Type().(type) {
+	case tftypes.Object, tftypes.Map:
+		var elements map[string]tftypes.Value
+		if err := v.As(&elements); err != nil {
+			return nil, err
+		}
+
+		result := make(map[string]interface{}, len(elements))
+		for k, element := range elements {
+			value, err := terraformValueToInterface(element)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", k, err)
+			}
+			result[k] = value
+		}
+
+		return result, nil
+	case tftypes.List, tftypes.Set, tftypes.Tuple:
+		var elements []tftypes.Value
+		if err := v.As(&elements); err != nil {
+			return nil, err
+		}
+
+		result := make([]interface{}, 0, len(elements))
+		for i, element := range elements {
+			value, err := terraformValueToInterface(element)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %s", i, err)
+			}
+			result = append(result, value)
+		}
+
+		return result, nil
+	}
+
+	switch {
+	case v.Type().Is(tftypes.String):
+		var s string
+		err := v.As(&s)
+		return s, err
+	case v.Type().Is(tftypes.Bool):
+		var b bool
+		err := v.As(&b)
+		return b, err
+	case v.Type().Is(tftypes.Number):
+		f := new(big.Float)
+		if err := v.As(&f); err != nil {
+			return nil, err
+		}
+		n, _ := f.Float64()
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
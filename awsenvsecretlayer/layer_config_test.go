@@ -0,0 +1,49 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayerConfigVars(t *testing.T) {
+	ctx := context.Background()
+
+	yamlConfig := `
+name: test
+port: 8080
+db:
+  host: localhost
+  user: app
+`
+	fromYaml, err := layerConfigVars(ctx, types.DynamicNull(), types.StringValue(yamlConfig))
+	assert.NoError(t, err)
+
+	config := types.DynamicValue(types.ObjectValueMust(
+		map[string]attr.Type{
+			"name": types.StringType,
+			"port": types.NumberType,
+			"db":   types.MapType{ElemType: types.StringType},
+		},
+		map[string]attr.Value{
+			"name": types.StringValue("test"),
+			"port": types.NumberValue(big.NewFloat(8080)),
+			"db": types.MapValueMust(types.StringType, map[string]attr.Value{
+				"host": types.StringValue("localhost"),
+				"user": types.StringValue("app"),
+			}),
+		},
+	))
+	fromConfig, err := layerConfigVars(ctx, config, types.StringValue(""))
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"name": "test", "db_db_host": "localhost", "db_db_user": "app"}, fromYaml)
+	assert.Equal(t, fromYaml, fromConfig)
+
+	_, err = layerConfigVars(ctx, types.DynamicValue(types.StringValue("test")), types.StringValue(""))
+	assert.Error(t, err)
+}
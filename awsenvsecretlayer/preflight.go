@@ -0,0 +1,64 @@
+package awsenvsecretlayer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+// preflightLambdaLayer checks at plan time that every secret can be read and
+// decrypted and that the layer can be listed in each of its regions, so that
+// a missing permission or a wrong ARN is not found halfway through an apply.
+// Every problem is reported, not just the first.
+func preflightLambdaLayer(client *AWSClient, layerName string, replicaRegions []string, secretSources []secretSource) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, source := range secretSources {
+		diags.Append(preflightSecretSource(client, source)...)
+	}
+
+	for _, region := range append([]string{""}, replicaRegions...) {
+		lambdaSvc := client.LambdaConn(region)
+
+		_, err := lambdaSvc.ListLayerVersions(&lambda.ListLayerVersionsInput{
+			LayerName: aws.String(layerName),
+			MaxItems:  aws.Int64(1),
+		})
+		if err != nil {
+			diags.AddAttributeError(path.Root("layer_name"), "Layer not reachable",
+				fmt.Sprintf("failed to list the versions of layer %s in %s: %s", layerName, aws.StringValue(lambdaSvc.Config.Region), err))
+		}
+	}
+
+	return diags
+}
+
+func preflightSecretSource(client *AWSClient, source secretSource) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	output, err := describeSecret(client, source)
+	if err != nil {
+		diags.AddAttributeError(source.attributePath(), "Secret not reachable",
+			fmt.Sprintf("failed to describe secret %s: %s", source.Arn, err))
+		return diags
+	}
+
+	if output.DeletedDate != nil {
+		diags.AddAttributeError(source.attributePath(), "Secret scheduled for deletion",
+			fmt.Sprintf("secret %s was scheduled for deletion on %s and cannot be read", source.Arn, output.DeletedDate.UTC().Format(time.RFC3339)))
+		return diags
+	}
+
+	// Describing a secret needs neither GetSecretValue nor kms:Decrypt, which
+	// only reading its value checks. The value itself is discarded.
+	if _, err := getSecretValue(client, source); err != nil {
+		diags.AddAttributeError(source.attributePath(), "Secret not readable",
+			fmt.Sprintf("failed to read secret %s: %s", source.Arn, err))
+	}
+
+	return diags
+}
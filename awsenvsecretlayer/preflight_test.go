@@ -0,0 +1,64 @@
+package awsenvsecretlayer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflightLambdaLayer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/2018-10-31/layers/") {
+			w.Write([]byte(`{"LayerVersions": []}`))
+			return
+		}
+
+		var input struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&input)
+
+		switch {
+		case strings.Contains(input.SecretId, "undecryptable") && strings.HasSuffix(r.Header.Get("X-Amz-Target"), "GetSecretValue"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "AccessDeniedException", "message": "Access to KMS is not allowed"}`))
+		case strings.Contains(input.SecretId, "deleted"):
+			w.Write([]byte(`{"ARN": "` + input.SecretId + `", "DeletedDate": 1767225600}`))
+		case strings.Contains(input.SecretId, "missing"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "ResourceNotFoundException", "message": "Secrets Manager can't find the specified secret."}`))
+		default:
+			w.Write([]byte(`{"ARN": "` + input.SecretId + `"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := testAWSClient("us-east-1", server.URL)
+
+	sources := expandSecretSources([]string{
+		"arn:aws:secretsmanager:us-east-1:111111111111:secret:present-AbCdEf",
+		"arn:aws:secretsmanager:us-east-1:111111111111:secret:missing-AbCdEf",
+		"arn:aws:secretsmanager:us-east-1:111111111111:secret:deleted-AbCdEf",
+		"arn:aws:secretsmanager:us-east-1:111111111111:secret:undecryptable-AbCdEf",
+	}, nil)
+
+	diags := preflightLambdaLayer(client, "example", nil, sources)
+	assert.Len(t, diags, 3)
+	assert.Equal(t, "Secret not reachable", diags[0].Summary())
+	assert.Equal(t, "Secret scheduled for deletion", diags[1].Summary())
+	assert.Equal(t, "Secret not readable", diags[2].Summary())
+
+	var paths []path.Path
+	for _, d := range diags {
+		paths = append(paths, d.(diag.DiagnosticWithPath).Path())
+	}
+	assert.Equal(t, []path.Path{
+		path.Root("secrets_arns").AtListIndex(1),
+		path.Root("secrets_arns").AtListIndex(2),
+		path.Root("secrets_arns").AtListIndex(3),
+	}, paths)
+}
@@ -2,10 +2,13 @@ package awsenvsecretlayer
 
 import (
 	"context"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func Provider() *schema.Provider {
@@ -22,32 +25,325 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.EnvDefaultFunc("AWS_PROFILE", nil),
 				Description: "The profile name as set in the shared credentials file for the provider.",
 			},
+			"shared_config_files": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "List of paths to shared config files. If not set, the default is [~/.aws/config].",
+			},
+			"shared_credentials_files": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "List of paths to shared credentials files. If not set, the default is [~/.aws/credentials].",
+			},
+			"assume_role": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"role_arn": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Amazon Resource Name (ARN) of the IAM Role to assume.",
+						},
+						"session_name": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "An identifier for the assumed role session.",
+						},
+						"external_id": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "A unique identifier that might be required when you assume a role in another account.",
+						},
+						"duration": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ValidateDiagFunc: validateDuration,
+							Description:      "The duration, between 15 minutes and 12 hours, of the role session. Valid time units are ns, us (or µs), ms, s, h, or m.",
+						},
+						"tags": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Assume role session tags.",
+						},
+						"source_identity": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Source identity specified by the principal assuming the role.",
+						},
+					},
+				},
+			},
+			"assume_role_with_web_identity": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"role_arn": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("AWS_ROLE_ARN", nil),
+							Description: "Amazon Resource Name (ARN) of the IAM Role to assume. Can also be set with the AWS_ROLE_ARN environment variable.",
+						},
+						"session_name": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("AWS_ROLE_SESSION_NAME", nil),
+							Description: "An identifier for the assumed role session. Can also be set with the AWS_ROLE_SESSION_NAME environment variable.",
+						},
+						"web_identity_token": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							Sensitive:     true,
+							ConflictsWith: []string{"assume_role_with_web_identity.0.web_identity_token_file"},
+							Description:   "The OAuth 2.0 access token or OpenID Connect ID token that is provided by the identity provider.",
+						},
+						"web_identity_token_file": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							DefaultFunc:   schema.EnvDefaultFunc("AWS_WEB_IDENTITY_TOKEN_FILE", nil),
+							ConflictsWith: []string{"assume_role_with_web_identity.0.web_identity_token"},
+							Description:   "File containing a web identity token from an OpenID Connect (OIDC) or OAuth provider. Can also be set with the AWS_WEB_IDENTITY_TOKEN_FILE environment variable.",
+						},
+						"duration": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ValidateDiagFunc: validateDuration,
+							Description:      "The duration, between 15 minutes and 12 hours, of the role session. Valid time units are ns, us (or µs), ms, s, h, or m.",
+						},
+					},
+				},
+			},
+			"endpoints": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"lambda": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ValidateDiagFunc: validateEndpoint,
+							Description:      "Use this to override the default service endpoint URL for Lambda.",
+						},
+						"secretsmanager": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ValidateDiagFunc: validateEndpoint,
+							Description:      "Use this to override the default service endpoint URL for Secrets Manager.",
+						},
+						"sts": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ValidateDiagFunc: validateEndpoint,
+							Description:      "Use this to override the default service endpoint URL for STS.",
+						},
+					},
+				},
+			},
+			"allowed_account_ids": {
+				Type:          schema.TypeSet,
+				Optional:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"forbidden_account_ids"},
+				Description:   "List of allowed AWS account IDs to prevent you from mistakenly using an incorrect one.",
+			},
+			"forbidden_account_ids": {
+				Type:          schema.TypeSet,
+				Optional:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"allowed_account_ids"},
+				Description:   "List of forbidden AWS account IDs to prevent you from mistakenly using the wrong one.",
+			},
+			"http_proxy": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: validateEndpoint,
+				Description:      "URL of a proxy to use for HTTP requests when accessing the AWS API. If not set, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.",
+			},
+			"custom_ca_bundle": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("AWS_CA_BUNDLE", nil),
+				Description: "File containing custom root and intermediate certificates. Can also be set with the AWS_CA_BUNDLE environment variable.",
+			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      25,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "The maximum number of times an AWS API request is retried on retryable and throttling errors.",
+			},
+			"retry_mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("AWS_RETRY_MODE", "standard"),
+				ValidateFunc: validation.StringInSlice([]string{"standard", "adaptive"}, false),
+				Description:  "Specifies how retries are attempted. Valid values are standard and adaptive. Unlike the adaptive mode of newer AWS SDKs, adaptive only lengthens the backoff after throttling errors and does not limit the request rate on the client. Can also be set with the AWS_RETRY_MODE environment variable.",
+			},
+			"default_layer_settings": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Settings inherited by every awsenvsecretlayer_lambda resource. A setting on the resource replaces the default, except envs_map, which is merged with the default variables.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"compatible_runtimes": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Default list of runtimes the layers are compatible with.",
+						},
+						"compatible_architectures": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 2,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.StringInSlice(lambda.Architecture_Values(), false),
+							},
+							Description: "Default list of instruction set architectures the layers are compatible with.",
+						},
+						"description": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringLenBetween(0, 256),
+							Description:  "Default description of the layer versions.",
+						},
+						"license_info": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringLenBetween(0, 512),
+							Description:  "Default software license of the layers.",
+						},
+						"license_files": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Default list of license files included in the layers.",
+						},
+						"envs_map": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Environment variables included in every layer. Variables of the same name in a layer's envs_map take precedence.",
+						},
+						"history_size": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validation.IntAtLeast(0),
+							Description:  "Default number of earlier layer versions to keep.",
+						},
+						"skip_destroy": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "Default for whether to keep old layer versions when publishing a new one.",
+						},
+					},
+				},
+			},
+			"force_secrets_refresh": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("AWSENVSECRETLAYER_FORCE_SECRETS_REFRESH", false),
+				Description: "Fetch secrets on every refresh and plan, ignoring the secrets_refresh_interval of the layers. Can also be set with the AWSENVSECRETLAYER_FORCE_SECRETS_REFRESH environment variable.",
+			},
+			"skip_credentials_validation": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Skip the credentials validation. Useful for AWS API implementations that do not have STS available or implemented.",
+			},
+			"skip_requesting_account_id": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Skip requesting the account ID with sts:GetCallerIdentity. Useful for AWS API implementations that do not have STS available or implemented.",
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"awsenvsecretlayer_lambda": resourceLambdaLayer(),
+			"awsenvsecretlayer_function_attachment": resourceFunctionAttachment(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{},
 		ConfigureContextFunc: func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
-			region := d.Get("region").(string)
-			profile := d.Get("profile").(string)
+			config := &Config{
+				Region:                    d.Get("region").(string),
+				Profile:                   d.Get("profile").(string),
+				SharedConfigFiles:         expandStringValueList(d.Get("shared_config_files").([]interface{})),
+				SharedCredentialsFiles:    expandStringValueList(d.Get("shared_credentials_files").([]interface{})),
+				Endpoints:                 make(map[string]string),
+				SkipCredentialsValidation: d.Get("skip_credentials_validation").(bool),
+				SkipRequestingAccountID:   d.Get("skip_requesting_account_id").(bool),
+				AllowedAccountIds:         expandStringValueList(d.Get("allowed_account_ids").(*schema.Set).List()),
+				ForbiddenAccountIds:       expandStringValueList(d.Get("forbidden_account_ids").(*schema.Set).List()),
+				HTTPProxy:                 d.Get("http_proxy").(string),
+				CustomCABundle:            d.Get("custom_ca_bundle").(string),
+				MaxRetries:                d.Get("max_retries").(int),
+				RetryMode:                 d.Get("retry_mode").(string),
+				ForceSecretsRefresh:       d.Get("force_secrets_refresh").(bool),
+			}
 
-			opts := session.Options{
-				Config: aws.Config{
-					Region: aws.String(region),
-				},
-				SharedConfigState: session.SharedConfigEnable,
+			if v, ok := d.GetOk("endpoints"); ok && v.([]interface{})[0] != nil {
+				for service, endpoint := range v.([]interface{})[0].(map[string]interface{}) {
+					config.Endpoints[service] = endpoint.(string)
+				}
+			}
+
+			if v, ok := d.GetOk("assume_role"); ok && v.([]interface{})[0] != nil {
+				config.AssumeRole = expandAssumeRole(v.([]interface{})[0].(map[string]interface{}))
 			}
 
-			if profile != "" {
-				opts.Profile = profile
+			if v, ok := d.GetOk("default_layer_settings"); ok && v.([]interface{})[0] != nil {
+				config.DefaultLayerSettings = expandDefaultLayerSettings(v.([]interface{})[0].(map[string]interface{}))
 			}
 
-			sess, err := session.NewSessionWithOptions(opts)
+			if v, ok := d.GetOk("assume_role_with_web_identity"); ok && v.([]interface{})[0] != nil {
+				config.AssumeRoleWithWebIdentity = expandAssumeRoleWithWebIdentity(v.([]interface{})[0].(map[string]interface{}))
+			}
+
+			client, err := config.Client()
 			if err != nil {
 				return nil, diag.FromErr(err)
 			}
 
-			return sess, nil
+			return client, nil
 		},
 	}
 }
+
+func expandAssumeRole(m map[string]interface{}) *AssumeRole {
+	assumeRole := &AssumeRole{
+		RoleARN:        m["role_arn"].(string),
+		SessionName:    m["session_name"].(string),
+		ExternalID:     m["external_id"].(string),
+		SourceIdentity: m["source_identity"].(string),
+		Tags:           make(map[string]string),
+	}
+
+	// The duration has already been validated by validateDuration
+	assumeRole.Duration, _ = time.ParseDuration(m["duration"].(string))
+
+	for k, v := range m["tags"].(map[string]interface{}) {
+		assumeRole.Tags[k] = v.(string)
+	}
+
+	return assumeRole
+}
+
+func expandAssumeRoleWithWebIdentity(m map[string]interface{}) *AssumeRoleWithWebIdentity {
+	assumeRole := &AssumeRoleWithWebIdentity{
+		RoleARN:              m["role_arn"].(string),
+		SessionName:          m["session_name"].(string),
+		WebIdentityToken:     m["web_identity_token"].(string),
+		WebIdentityTokenFile: m["web_identity_token_file"].(string),
+	}
+
+	assumeRole.Duration, _ = time.ParseDuration(m["duration"].(string))
+
+	return assumeRole
+}
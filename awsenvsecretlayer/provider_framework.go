@@ -0,0 +1,173 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	pschema "github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-mux/tf5muxserver"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// ProtoV5ProviderServerFactory muxes the SDKv2 provider, which serves the
+// resources that have not moved yet, with the plugin framework provider. The
+// SDKv2 provider is configured first and the framework provider shares its
+// *AWSClient.
+func ProtoV5ProviderServerFactory(ctx context.Context) (func() tfprotov5.ProviderServer, error) {
+	primary := Provider()
+
+	servers := []func() tfprotov5.ProviderServer{
+		primary.GRPCProvider,
+		providerserver.NewProtocol5(NewFrameworkProvider(primary)),
+	}
+
+	muxServer, err := tf5muxserver.NewMuxServer(ctx, servers...)
+	if err != nil {
+		return nil, err
+	}
+
+	return muxServer.ProviderServer, nil
+}
+
+var (
+	_ provider.Provider                       = &frameworkProvider{}
+	_ provider.ProviderWithEphemeralResources = &frameworkProvider{}
+	_ provider.ProviderWithFunctions          = &frameworkProvider{}
+)
+
+type frameworkProvider struct {
+	primary *schema.Provider
+}
+
+// NewFrameworkProvider returns the plugin framework half of the provider.
+func NewFrameworkProvider(primary *schema.Provider) provider.Provider {
+	return &frameworkProvider{primary: primary}
+}
+
+func (p *frameworkProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "awsenvsecretlayer"
+}
+
+// Schema mirrors the SDKv2 provider schema, since muxed servers must agree on
+// it. Validation and defaults are left to the SDKv2 provider.
+func (p *frameworkProvider) Schema(ctx context.Context, req provider.SchemaRequest, resp *provider.SchemaResponse) {
+	resp.Schema = frameworkProviderSchema(p.primary.Schema)
+}
+
+func (p *frameworkProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	client, ok := p.primary.Meta().(*AWSClient)
+	if !ok {
+		resp.Diagnostics.AddError("Provider not configured", "The AWS client of the SDKv2 provider is not available.")
+		return
+	}
+
+	resp.ResourceData = client
+	resp.EphemeralResourceData = client
+	resp.DataSourceData = client
+}
+
+func (p *frameworkProvider) Resources(ctx context.Context) []func() resource.Resource {
+	return []func() resource.Resource{
+		newLambdaLayerResource,
+	}
+}
+
+func (p *frameworkProvider) EphemeralResources(ctx context.Context) []func() ephemeral.EphemeralResource {
+	return []func() ephemeral.EphemeralResource{
+		newSecretValuesEphemeralResource,
+	}
+}
+
+func (p *frameworkProvider) Functions(ctx context.Context) []func() function.Function {
+	return []func() function.Function{
+		newFlattenFunction,
+		newRenderDotenvFunction,
+	}
+}
+
+func (p *frameworkProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
+	return []func() datasource.DataSource{
+		newRenderedEnvDataSource,
+		newLayerDataSource,
+		newSecretKeysDataSource,
+		newIAMPolicyDataSource,
+	}
+}
+
+func frameworkProviderSchema(sdkSchema map[string]*schema.Schema) pschema.Schema {
+	attributes, blocks := frameworkProviderAttributes(sdkSchema)
+
+	return pschema.Schema{
+		Attributes: attributes,
+		Blocks:     blocks,
+	}
+}
+
+func frameworkProviderAttributes(sdkSchema map[string]*schema.Schema) (map[string]pschema.Attribute, map[string]pschema.Block) {
+	attributes := make(map[string]pschema.Attribute)
+	blocks := make(map[string]pschema.Block)
+
+	for name, s := range sdkSchema {
+		if r, ok := s.Elem.(*schema.Resource); ok {
+			nestedAttributes, nestedBlocks := frameworkProviderAttributes(r.Schema)
+			blocks[name] = pschema.ListNestedBlock{
+				Description: s.Description,
+				NestedObject: pschema.NestedBlockObject{
+					Attributes: nestedAttributes,
+					Blocks:     nestedBlocks,
+				},
+			}
+			continue
+		}
+
+		attributes[name] = frameworkProviderAttribute(s)
+	}
+
+	return attributes, blocks
+}
+
+func frameworkProviderAttribute(s *schema.Schema) pschema.Attribute {
+	required, optional := s.Required, !s.Required
+
+	switch s.Type {
+	case schema.TypeBool:
+		return pschema.BoolAttribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description}
+	case schema.TypeInt:
+		return pschema.Int64Attribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description}
+	case schema.TypeString:
+		return pschema.StringAttribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description}
+	case schema.TypeList:
+		return pschema.ListAttribute{ElementType: frameworkElementType(s), Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description}
+	case schema.TypeSet:
+		return pschema.SetAttribute{ElementType: frameworkElementType(s), Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description}
+	case schema.TypeMap:
+		return pschema.MapAttribute{ElementType: frameworkElementType(s), Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description}
+	}
+
+	panic(fmt.Sprintf("unsupported provider attribute type %s", s.Type))
+}
+
+func frameworkElementType(s *schema.Schema) attr.Type {
+	elem, ok := s.Elem.(*schema.Schema)
+	if !ok {
+		return types.StringType
+	}
+
+	switch elem.Type {
+	case schema.TypeBool:
+		return types.BoolType
+	case schema.TypeInt:
+		return types.Int64Type
+	}
+
+	return types.StringType
+}
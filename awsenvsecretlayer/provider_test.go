@@ -0,0 +1,68 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+)
+
+func TestProvider(t *testing.T) {
+	if err := Provider().InternalValidate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestProtoV5ProviderServerFactory(t *testing.T) {
+	ctx := context.Background()
+
+	serverFactory, err := ProtoV5ProviderServerFactory(ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The muxed servers must agree on the provider schema
+	resp, err := serverFactory().GetProviderSchema(ctx, &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, d := range resp.Diagnostics {
+		t.Errorf("%s: %s", d.Summary, d.Detail)
+	}
+
+	for _, name := range []string{"awsenvsecretlayer_lambda", "awsenvsecretlayer_function_attachment"} {
+		if _, ok := resp.ResourceSchemas[name]; !ok {
+			t.Errorf("resource %s is not served", name)
+		}
+	}
+	if _, ok := resp.EphemeralResourceSchemas["awsenvsecretlayer_secret_values"]; !ok {
+		t.Errorf("ephemeral resource awsenvsecretlayer_secret_values is not served")
+	}
+	for _, name := range []string{"awsenvsecretlayer_rendered_env", "awsenvsecretlayer_layer", "awsenvsecretlayer_secret_keys", "awsenvsecretlayer_iam_policy"} {
+		if _, ok := resp.DataSourceSchemas[name]; !ok {
+			t.Errorf("data source %s is not served", name)
+		}
+	}
+	for _, name := range []string{"flatten", "render_dotenv"} {
+		if _, ok := resp.Functions[name]; !ok {
+			t.Errorf("function %s is not served", name)
+		}
+	}
+}
+
+func TestValidateDuration(t *testing.T) {
+	for value, valid := range map[string]bool{
+		"":      true,
+		"1h":    true,
+		"15m":   true,
+		"10m":   false,
+		"13h":   false,
+		"1 day": false,
+	} {
+		diags := validateDuration(value, cty.Path{})
+		if diags.HasError() == valid {
+			t.Errorf("validateDuration(%q): got errors %v, want valid %t", value, diags, valid)
+		}
+	}
+}
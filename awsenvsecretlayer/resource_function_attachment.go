@@ -0,0 +1,248 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/internal/arns"
+)
+
+func resourceFunctionAttachment() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceFunctionAttachmentCreate,
+		ReadContext:   resourceFunctionAttachmentRead,
+		UpdateContext: resourceFunctionAttachmentUpdate,
+		DeleteContext: resourceFunctionAttachmentDelete,
+		Schema: map[string]*schema.Schema{
+			"function_name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"layer_id": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateDiagFunc: validateLayerVersionArn,
+			},
+			"publish_version": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"alias_name": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"function_version": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceFunctionAttachmentCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	functionName := d.Get("function_name").(string)
+	layerVersionArn := d.Get("layer_id").(string)
+
+	layerVersion, err := arns.ParseLayerVersion(layerVersionArn)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := attachLayerVersion(ctx, d, m, ""); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(fmt.Sprintf("%s,%s", functionName, layerVersion.Layer))
+
+	return resourceFunctionAttachmentRead(ctx, d, m)
+}
+
+func resourceFunctionAttachmentRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	lambdaSvc := m.(*AWSClient).LambdaConn("")
+	functionName, layerArn := parseFunctionAttachmentId(d.Id())
+
+	output, err := lambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+		logger.Debug("resourceFunctionAttachmentRead function not found, removing from state", "function", functionName)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Report the version actually attached so that out-of-band changes show up
+	// as a diff against the configured layer_id
+	attachedLayerVersionArn := ""
+	for _, layer := range output.Layers {
+		if layerArnOf(aws.StringValue(layer.Arn)) == layerArn {
+			attachedLayerVersionArn = aws.StringValue(layer.Arn)
+		}
+	}
+
+	d.Set("function_name", functionName)
+	d.Set("layer_id", attachedLayerVersionArn)
+
+	return nil
+}
+
+func resourceFunctionAttachmentUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if !d.HasChanges("layer_id", "publish_version", "alias_name") {
+		return nil
+	}
+
+	// A different layer replaces the one this attachment managed before
+	_, previousLayerArn := parseFunctionAttachmentId(d.Id())
+
+	layerVersion, err := arns.ParseLayerVersion(d.Get("layer_id").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := attachLayerVersion(ctx, d, m, previousLayerArn); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(fmt.Sprintf("%s,%s", d.Get("function_name").(string), layerVersion.Layer))
+
+	return resourceFunctionAttachmentRead(ctx, d, m)
+}
+
+func resourceFunctionAttachmentDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	lambdaSvc := m.(*AWSClient).LambdaConn("")
+	functionName, layerArn := parseFunctionAttachmentId(d.Id())
+
+	output, err := lambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	layers := flattenFunctionLayers(output.Layers)
+	remainingLayers := removeLayer(layers, layerArn)
+	if len(remainingLayers) == len(layers) {
+		return nil
+	}
+
+	if err := updateFunctionLayers(ctx, lambdaSvc, functionName, remainingLayers); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+// attachLayerVersion points the function at the configured layer version,
+// leaving the function's other layers in place, and publishes a function
+// version and alias when requested.
+func attachLayerVersion(ctx context.Context, d *schema.ResourceData, m interface{}, previousLayerArn string) error {
+	lambdaSvc := m.(*AWSClient).LambdaConn("")
+	functionName := d.Get("function_name").(string)
+	layerVersionArn := d.Get("layer_id").(string)
+
+	output, err := lambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read function %s: %s", functionName, err)
+	}
+
+	layers := flattenFunctionLayers(output.Layers)
+	if previousLayerArn != "" {
+		if layerArnOf(layerVersionArn) != previousLayerArn {
+			layers = removeLayer(layers, previousLayerArn)
+		}
+	}
+
+	logger.Debug("attachLayerVersion", "function", functionName, "layerVersionArn", layerVersionArn)
+	if err := updateFunctionLayers(ctx, lambdaSvc, functionName, replaceLayerVersion(layers, layerVersionArn)); err != nil {
+		return err
+	}
+
+	aliasName := d.Get("alias_name").(string)
+	if !d.Get("publish_version").(bool) && aliasName == "" {
+		d.Set("function_version", "")
+		return nil
+	}
+
+	version, err := lambdaSvc.PublishVersionWithContext(ctx, &lambda.PublishVersionInput{
+		FunctionName: aws.String(functionName),
+		Description:  aws.String(fmt.Sprintf("Layer %s", layerVersionArn)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish version of function %s: %s", functionName, err)
+	}
+	d.Set("function_version", aws.StringValue(version.Version))
+
+	if aliasName == "" {
+		return nil
+	}
+
+	_, err = lambdaSvc.UpdateAliasWithContext(ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(functionName),
+		Name:            aws.String(aliasName),
+		FunctionVersion: version.Version,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+		_, err = lambdaSvc.CreateAliasWithContext(ctx, &lambda.CreateAliasInput{
+			FunctionName:    aws.String(functionName),
+			Name:            aws.String(aliasName),
+			FunctionVersion: version.Version,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to point alias %s of function %s at version %s: %s", aliasName, functionName, aws.StringValue(version.Version), err)
+	}
+
+	return nil
+}
+
+func updateFunctionLayers(ctx context.Context, lambdaSvc *lambda.Lambda, functionName string, layers []string) error {
+	_, err := lambdaSvc.UpdateFunctionConfigurationWithContext(ctx, &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+		Layers:       aws.StringSlice(layers),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update layers of function %s: %s", functionName, err)
+	}
+
+	err = lambdaSvc.WaitUntilFunctionUpdatedV2WithContext(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed waiting for function %s to update: %s", functionName, err)
+	}
+
+	return nil
+}
+
+func flattenFunctionLayers(layers []*lambda.Layer) []string {
+	result := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		result = append(result, aws.StringValue(layer.Arn))
+	}
+
+	return result
+}
+
+func parseFunctionAttachmentId(id string) (string, string) {
+	parts := strings.SplitN(id, ",", 2)
+	if len(parts) != 2 {
+		return id, ""
+	}
+
+	return parts[0], parts[1]
+}
@@ -4,15 +4,32 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework-validators/dynamicvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/internal/arns"
 
 	hclog "github.com/hashicorp/go-hclog"
 )
@@ -23,389 +40,1595 @@ var logger = hclog.New(&hclog.LoggerOptions{
 	JSONFormat: false,
 })
 
-func resourceLambdaLayer() *schema.Resource {
-	return &schema.Resource{
-		CreateContext: resourceLambdaLayerCreate,
-		ReadContext:   resourceLambdaLayerRead,
-		DeleteContext: resourceLambdaLayerDelete,
-		UpdateContext: resourceLambdaLayerUpdate,
-		CustomizeDiff: resourceLambdaLayerCustomizeDiff,
-		Schema: map[string]*schema.Schema{
-			"yaml_config": {
-				Type:     schema.TypeString,
+var (
+	_ resource.Resource               = &lambdaLayerResource{}
+	_ resource.ResourceWithConfigure  = &lambdaLayerResource{}
+	_ resource.ResourceWithModifyPlan = &lambdaLayerResource{}
+)
+
+type lambdaLayerResource struct {
+	client *AWSClient
+}
+
+func newLambdaLayerResource() resource.Resource {
+	return &lambdaLayerResource{}
+}
+
+type lambdaLayerResourceModel struct {
+	ID                      types.String  `tfsdk:"id"`
+	YamlConfig              types.String  `tfsdk:"yaml_config"`
+	Config                  types.Dynamic `tfsdk:"config"`
+	SecretsArns             types.List    `tfsdk:"secrets_arns"`
+	SecretSource            types.List    `tfsdk:"secret_source"`
+	EnvsMap                 types.Map     `tfsdk:"envs_map"`
+	EffectiveEnvsMap        types.Map     `tfsdk:"effective_envs_map"`
+	EnvsMapWO               types.Map     `tfsdk:"envs_map_wo"`
+	EnvsMapWOVersion        types.Int64   `tfsdk:"envs_map_wo_version"`
+	Triggers                types.Map     `tfsdk:"triggers"`
+	StoredSecretsHash       types.String  `tfsdk:"stored_secrets_hash"`
+	LayerName               types.String  `tfsdk:"layer_name"`
+	Region                  types.String  `tfsdk:"region"`
+	FileName                types.String  `tfsdk:"file_name"`
+	LicenseFiles            types.List    `tfsdk:"license_files"`
+	CompatibleRuntimes      types.List    `tfsdk:"compatible_runtimes"`
+	CompatibleArchitectures types.List    `tfsdk:"compatible_architectures"`
+	Description             types.String  `tfsdk:"description"`
+	LicenseInfo             types.String  `tfsdk:"license_info"`
+	Permission              types.List    `tfsdk:"permission"`
+	ReplicaRegions          types.List    `tfsdk:"replica_regions"`
+	PreferLocalReplicas     types.Bool    `tfsdk:"prefer_local_secret_replicas"`
+	HistorySize             types.Int64   `tfsdk:"history_size"`
+	PinnedVersion           types.Int64   `tfsdk:"pinned_version"`
+	SkipDestroy             types.Bool    `tfsdk:"skip_destroy"`
+	TrackActualSecrets      types.Bool    `tfsdk:"track_actual_secrets"`
+	SecretsRefreshInterval  types.String  `tfsdk:"secrets_refresh_interval"`
+	SecretsFetchedAt        types.String  `tfsdk:"secrets_fetched_at"`
+	Preflight               types.Bool    `tfsdk:"preflight"`
+	NeedUpdate              types.Bool    `tfsdk:"need_update"`
+	SecretsLastChanged      types.String  `tfsdk:"secrets_last_changed"`
+	LayerID                 types.String  `tfsdk:"layer_id"`
+	LayerArn                types.String  `tfsdk:"layer_arn"`
+	Version                 types.Int64   `tfsdk:"version"`
+	CodeSha256              types.String  `tfsdk:"code_sha256"`
+	CodeSize                types.Int64   `tfsdk:"code_size"`
+	CreatedDate             types.String  `tfsdk:"created_date"`
+	ReplicaLayerIDs         types.Map     `tfsdk:"replica_layer_ids"`
+	VersionHistory          types.List    `tfsdk:"version_history"`
+}
+
+// layerPermission is a permission block.
+type layerPermission struct {
+	Principal      string `tfsdk:"principal"`
+	OrganizationID string `tfsdk:"organization_id"`
+	Action         string `tfsdk:"action"`
+}
+
+// versionHistoryEntry is an element of version_history.
+type versionHistoryEntry struct {
+	Version         int64             `tfsdk:"version"`
+	LayerID         string            `tfsdk:"layer_id"`
+	CodeSha256      string            `tfsdk:"code_sha256"`
+	CodeSize        int64             `tfsdk:"code_size"`
+	SecretsHash     string            `tfsdk:"secrets_hash"`
+	CreatedDate     string            `tfsdk:"created_date"`
+	ReplicaLayerIDs map[string]string `tfsdk:"replica_layer_ids"`
+}
+
+var versionHistoryEntryType = types.ObjectType{
+	AttrTypes: map[string]attr.Type{
+		"version":           types.Int64Type,
+		"layer_id":          types.StringType,
+		"code_sha256":       types.StringType,
+		"code_size":         types.Int64Type,
+		"secrets_hash":      types.StringType,
+		"created_date":      types.StringType,
+		"replica_layer_ids": types.MapType{ElemType: types.StringType},
+	},
+}
+
+func (r *lambdaLayerResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_lambda"
+}
+
+func (r *lambdaLayerResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	r.client = req.ProviderData.(*AWSClient)
+}
+
+func (r *lambdaLayerResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+			},
+			"yaml_config": schema.StringAttribute{
 				Optional: true,
-				Default:  "",
-			},
-			"secrets_arns": {
-				Type:      schema.TypeList,
-				Optional:  true,
-				Sensitive: true,
-				Elem: &schema.Schema{
-					Type: schema.TypeString,
+				Computed: true,
+				Default:  stringdefault.StaticString(""),
+			},
+			"config": schema.DynamicAttribute{
+				Optional: true,
+				Validators: []validator.Dynamic{
+					dynamicvalidator.ConflictsWith(path.MatchRoot("yaml_config")),
+					layerConfigValidator{},
+				},
+			},
+			"secrets_arns": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Sensitive:   true,
+				Validators: []validator.List{
+					listvalidator.ValueStringsAre(secretArnValidator{}),
 				},
 			},
-			"envs_map": {
-				Type:     schema.TypeMap,
+			"envs_map": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Computed:    true,
+			},
+			"effective_envs_map": schema.MapAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"envs_map_wo": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Sensitive:   true,
+				WriteOnly:   true,
+			},
+			"envs_map_wo_version": schema.Int64Attribute{
 				Optional: true,
-				Elem:     &schema.Schema{Type: schema.TypeString},
 			},
-			"stored_secrets_hash": {
-				Type:     schema.TypeString,
+			"triggers": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"stored_secrets_hash": schema.StringAttribute{
 				Optional: true,
 				Computed: true,
 			},
-			"layer_name": {
-				Type:     schema.TypeString,
+			"layer_name": schema.StringAttribute{
 				Required: true,
 			},
-			"file_name": {
-				Type:     schema.TypeString,
+			"region": schema.StringAttribute{
+				Optional: true,
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"file_name": schema.StringAttribute{
 				Required: true,
 			},
-			"license_files": {
-				Type:     schema.TypeList,
+			"license_files": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Computed:    true,
+			},
+			"compatible_runtimes": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Computed:    true,
+			},
+			"compatible_architectures": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Computed:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(2),
+					listvalidator.ValueStringsAre(stringvalidator.OneOf(lambda.Architecture_Values()...)),
+				},
+			},
+			"description": schema.StringAttribute{
+				Optional: true,
+				Computed: true,
+				Validators: []validator.String{
+					stringvalidator.LengthBetween(0, 256),
+				},
+			},
+			"license_info": schema.StringAttribute{
+				Optional: true,
+				Computed: true,
+				Validators: []validator.String{
+					stringvalidator.LengthBetween(0, 512),
+				},
+			},
+			"replica_regions": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"prefer_local_secret_replicas": schema.BoolAttribute{
+				Optional: true,
+			},
+			"history_size": schema.Int64Attribute{
 				Optional: true,
-				Elem: &schema.Schema{
-					Type: schema.TypeString,
+				Computed: true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
 				},
 			},
-			"compatible_runtimes": {
-				Type:     schema.TypeList,
+			"pinned_version": schema.Int64Attribute{
 				Optional: true,
-				Elem: &schema.Schema{
-					Type: schema.TypeString,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
 				},
 			},
-			"skip_destroy": {
-				Type:     schema.TypeBool,
+			"skip_destroy": schema.BoolAttribute{
 				Optional: true,
-				Default:  false,
+				Computed: true,
 			},
-			"track_actual_secrets": {
-				Type:     schema.TypeBool,
+			"track_actual_secrets": schema.BoolAttribute{
 				Optional: true,
-				Default:  true,
+				Computed: true,
+				Default:  booldefault.StaticBool(true),
+			},
+			"secrets_refresh_interval": schema.StringAttribute{
+				Optional: true,
+				Validators: []validator.String{
+					durationValidator{},
+				},
+			},
+			"secrets_fetched_at": schema.StringAttribute{
+				Computed: true,
+			},
+			"preflight": schema.BoolAttribute{
+				Optional: true,
+			},
+			"need_update": schema.BoolAttribute{
+				Computed: true,
+			},
+			"secrets_last_changed": schema.StringAttribute{
+				Computed: true,
+			},
+			"layer_id": schema.StringAttribute{
+				Computed: true,
+			},
+			"layer_arn": schema.StringAttribute{
+				Computed: true,
+			},
+			"version": schema.Int64Attribute{
+				Computed: true,
+			},
+			"code_sha256": schema.StringAttribute{
+				Computed: true,
 			},
-			"need_update": {
-				Type:     schema.TypeBool,
+			"code_size": schema.Int64Attribute{
 				Computed: true,
 			},
-			"layer_id": {
-				Type:     schema.TypeString,
+			"created_date": schema.StringAttribute{
 				Computed: true,
 			},
+			"replica_layer_ids": schema.MapAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"version_history": schema.ListAttribute{
+				ElementType: versionHistoryEntryType,
+				Computed:    true,
+			},
 		},
+		Blocks: map[string]schema.Block{
+			"secret_source": schema.ListNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"arn": schema.StringAttribute{
+							Required: true,
+							Validators: []validator.String{
+								secretArnValidator{},
+							},
+						},
+						"role_arn": schema.StringAttribute{
+							Optional: true,
+						},
+						"region": schema.StringAttribute{
+							Optional: true,
+						},
+					},
+				},
+			},
+			"permission": schema.ListNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"principal": schema.StringAttribute{
+							Required: true,
+						},
+						"organization_id": schema.StringAttribute{
+							Optional: true,
+						},
+						"action": schema.StringAttribute{
+							Optional: true,
+							Computed: true,
+							Default:  stringdefault.StaticString("lambda:GetLayerVersion"),
+							Validators: []validator.String{
+								stringvalidator.OneOf("lambda:GetLayerVersion"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *lambdaLayerResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan lambdaLayerResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+
+	var envsMapWO types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("envs_map_wo"), &envsMapWO)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client := r.client.WithRegion(plan.Region.ValueString())
+
+	resp.Diagnostics.Append(publishLambdaLayerVersion(ctx, client, &plan, stringMapValue(ctx, envsMapWO), nil)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(setSecretsLastChanged(ctx, client, &plan)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (r *lambdaLayerResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state lambdaLayerResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client := r.client.WithRegion(state.Region.ValueString())
+
+	// Layers created before the region argument existed take it from their ARN
+	if state.Region.ValueString() == "" {
+		if layer, err := arns.ParseLayer(state.ID.ValueString()); err == nil {
+			state.Region = types.StringValue(layer.Region)
+		}
+	}
+
+	// States written before the layer version attributes existed only hold
+	// layer_id, which they are filled in from
+	if state.Version.IsNull() || state.VersionHistory.IsNull() {
+		resp.Diagnostics.Append(backfillLayerVersionAttributes(ctx, client, &state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// A pinned layer points at a retained version, so secrets are not fetched,
+	// and neither are they while the last fetch is recent enough
+	now := time.Now()
+	if state.PinnedVersion.IsNull() && secretsRefreshDue(client.ForceSecretsRefresh, state.SecretsRefreshInterval.ValueString(), state.SecretsFetchedAt.ValueString(), now) {
+		secretSources, diags := expandLambdaLayerSecretSources(ctx, state)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		fetchedSecretsHash, err := fetchSecrets(secretSources, client, false, state.TrackActualSecrets.ValueBool())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to fetch secrets", err.Error())
+			return
+		}
+
+		if fetchedSecretsHash != state.StoredSecretsHash.ValueString() {
+			state.NeedUpdate = types.BoolValue(true)
+		}
+
+		state.SecretsLastChanged = timeValueOrNull(latestSecretChange(describeSecretSources(client, secretSources)))
+		state.SecretsFetchedAt = types.StringValue(formatTime(now))
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+}
+
+func (r *lambdaLayerResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	logger.Debug("running lambdaLayerResource Update...")
+
+	var plan, state lambdaLayerResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	var envsMapWO types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("envs_map_wo"), &envsMapWO)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client := r.client.WithRegion(plan.Region.ValueString())
+
+	// ModifyPlan leaves the layer version unknown exactly when a new version
+	// has to be published
+	if plan.LayerID.IsUnknown() {
+		logger.Debug("lambdaLayerResource Update publishing a new layer version")
+		skipDestroy := plan.SkipDestroy.ValueBool()
+		logger.Debug("skipDestroy", "value", skipDestroy)
+
+		if !skipDestroy {
+			resp.Diagnostics.Append(pruneLambdaLayerVersions(ctx, client, state, int(plan.HistorySize.ValueInt64()))...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+		}
+
+		history, diags := versionHistoryValue(ctx, state.VersionHistory)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		resp.Diagnostics.Append(publishLambdaLayerVersion(ctx, client, &plan, stringMapValue(ctx, envsMapWO), history)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		resp.Diagnostics.Append(setSecretsLastChanged(ctx, client, &plan)...)
+		resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+		return
+	}
+
+	// A pinned version keeps the sharing it was published with, so it gets
+	// the current permission blocks whatever they were then
+	if plan.LayerID.ValueString() != state.LayerID.ValueString() {
+		logger.Debug("lambdaLayerResource Update pinning layer version", "value", plan.LayerID.ValueString())
+		resp.Diagnostics.Append(syncLayerVersionPermissions(ctx, client, plan)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	} else if !plan.Permission.Equal(state.Permission) {
+		resp.Diagnostics.Append(updateLayerVersionPermissions(ctx, client, state, plan)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	resp.Diagnostics.Append(setSecretsLastChanged(ctx, client, &plan)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (r *lambdaLayerResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state lambdaLayerResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client := r.client.WithRegion(state.Region.ValueString())
+	layerARN := state.ID.ValueString()
+	logger.Debug("lambdaLayerResource Delete", "layerARN", layerARN)
+
+	layer, err := arns.ParseLayer(layerARN)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid resource ID", err.Error())
+		return
+	}
+
+	if err := deleteLambdaLayerVersions(layer, client, nil); err != nil {
+		resp.Diagnostics.AddError("Failed to delete layer versions", err.Error())
+		return
+	}
+
+	for region, replicaLayerId := range stringMapValue(ctx, state.ReplicaLayerIDs) {
+		replicaLayerVersion, err := arns.ParseLayerVersion(replicaLayerId)
+		if err != nil {
+			resp.Diagnostics.AddError("Invalid replica layer ID", fmt.Sprintf("replica_layer_ids.%s: %s", region, err))
+			return
+		}
+
+		if err := deleteLambdaLayerVersions(replicaLayerVersion.Layer, client, nil); err != nil {
+			resp.Diagnostics.AddError("Failed to delete layer versions", fmt.Sprintf("failed to delete layer versions in %s: %s", region, err))
+			return
+		}
+	}
+}
+
+// ModifyPlan plans the effective arguments and decides whether a new layer
+// version is published. The layer version attributes stay known unless the
+// arguments, the secrets or the pinned version change.
+func (r *lambdaLayerResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var config, plan lambdaLayerResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var state *lambdaLayerResourceModel
+	if !req.State.Raw.IsNull() {
+		state = &lambdaLayerResourceModel{}
+		resp.Diagnostics.Append(req.State.Get(ctx, state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// Without a provider configuration there is nothing to compare against
+	if r.client == nil {
+		return
+	}
+
+	client := r.client.WithRegion(plan.Region.ValueString())
+	if plan.Region.IsUnknown() {
+		plan.Region = types.StringValue(client.Region)
+	}
+
+	resp.Diagnostics.Append(applyDefaultLayerSettings(ctx, config, &plan, client.DefaultLayerSettings)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if plan.Preflight.ValueBool() && plan.PinnedVersion.IsNull() {
+		resp.Diagnostics.Append(preflightLambdaLayerPlan(ctx, client, plan)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if state == nil {
+		if !plan.PinnedVersion.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("pinned_version"), "Invalid pinned_version",
+				fmt.Sprintf("pinned_version %d is not in version_history, a new layer has no earlier versions", plan.PinnedVersion.ValueInt64()))
+			return
+		}
+
+		// Secrets are read at plan time so that missing access fails early
+		if _, diags := fetchLambdaLayerSecretsHash(ctx, client, plan, true); diags.HasError() {
+			resp.Diagnostics.Append(diags...)
+			return
+		}
+
+		resp.Diagnostics.Append(planSecretsStatus(ctx, client, &plan, nil, time.Time{})...)
+
+		setLayerVersionUnknown(&plan, config, true)
+		resp.Diagnostics.Append(resp.Plan.Set(ctx, plan)...)
+		return
+	}
+
+	// Pointing back at a retained version needs neither secrets nor a publish
+	if !plan.PinnedVersion.IsNull() {
+		resp.Diagnostics.Append(planPinnedVersion(ctx, &plan, *state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		if secretsRefreshDue(client.ForceSecretsRefresh, plan.SecretsRefreshInterval.ValueString(), state.SecretsFetchedAt.ValueString(), time.Now()) {
+			builtAt, _ := parseLayerCreatedDate(plan.CreatedDate.ValueString())
+			resp.Diagnostics.Append(planSecretsStatus(ctx, client, &plan, state, builtAt)...)
+		} else {
+			plan.SecretsLastChanged = state.SecretsLastChanged
+		}
+
+		resp.Diagnostics.Append(resp.Plan.Set(ctx, plan)...)
+		return
+	}
+
+	// Unpinning publishes a fresh version from the current arguments
+	publish := !state.PinnedVersion.IsNull() || state.NeedUpdate.ValueBool() || layerVersionInputsChanged(ctx, plan, *state)
+
+	// Within secrets_refresh_interval the stored hash stands in for the
+	// secrets, unless the sources themselves changed
+	arnsChanged := secretSourcesChanged(plan, *state)
+	if arnsChanged || secretsRefreshDue(client.ForceSecretsRefresh, plan.SecretsRefreshInterval.ValueString(), state.SecretsFetchedAt.ValueString(), time.Now()) {
+		fetchedSecretsHash, diags := fetchLambdaLayerSecretsHash(ctx, client, plan, arnsChanged)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		logger.Debug("lambdaLayerResource ModifyPlan fetchedSecretsHash", "value", fetchedSecretsHash.ValueString())
+		logger.Debug("lambdaLayerResource ModifyPlan storedSecretsHash", "value", state.StoredSecretsHash.ValueString())
+
+		if !fetchedSecretsHash.Equal(state.StoredSecretsHash) {
+			publish = true
+		}
+
+		// Only a layer version that stays in place can be older than a rotation
+		var builtAt time.Time
+		if !publish {
+			builtAt, _ = parseLayerCreatedDate(state.CreatedDate.ValueString())
+		}
+		resp.Diagnostics.Append(planSecretsStatus(ctx, client, &plan, state, builtAt)...)
+	} else {
+		logger.Debug("lambdaLayerResource ModifyPlan reusing the stored secrets hash", "fetchedAt", state.SecretsFetchedAt.ValueString())
+		plan.SecretsLastChanged = state.SecretsLastChanged
 	}
+
+	if publish {
+		plan.ID, plan.LayerArn = state.ID, state.LayerArn
+		setLayerVersionUnknown(&plan, config, plan.LayerName.ValueString() != state.LayerName.ValueString())
+	} else {
+		copyLayerVersionAttributes(&plan, *state)
+	}
+
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, plan)...)
+}
+
+// planSecretsStatus warns about the rotation state of the secrets and plans
+// secrets_last_changed, which is only unknown when a secret changed since
+// the last refresh.
+func planSecretsStatus(ctx context.Context, client *AWSClient, plan *lambdaLayerResourceModel, state *lambdaLayerResourceModel, builtAt time.Time) diag.Diagnostics {
+	plan.SecretsLastChanged = types.StringUnknown()
+
+	if !isFullyKnown(ctx, plan.SecretsArns) || !isFullyKnown(ctx, plan.SecretSource) {
+		return nil
+	}
+
+	secretSources, diags := expandLambdaLayerSecretSources(ctx, *plan)
+	if diags.HasError() {
+		return diags
+	}
+
+	statuses := describeSecretSources(client, secretSources)
+	diags.Append(secretRotationWarnings(statuses, builtAt, time.Now())...)
+
+	lastChanged := timeValueOrNull(latestSecretChange(statuses))
+	if state != nil && lastChanged.Equal(state.SecretsLastChanged) {
+		plan.SecretsLastChanged = lastChanged
+	}
+
+	return diags
 }
 
-func resourceLambdaLayerCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	sess := m.(*session.Session)
+// setSecretsLastChanged fills in secrets_last_changed after an apply when the
+// plan left it unknown.
+func setSecretsLastChanged(ctx context.Context, client *AWSClient, plan *lambdaLayerResourceModel) diag.Diagnostics {
+	if !plan.SecretsLastChanged.IsUnknown() {
+		return nil
+	}
 
-	content, err, secretHash := createEnvFileContent(d, sess)
+	secretSources, diags := expandLambdaLayerSecretSources(ctx, *plan)
+	if diags.HasError() {
+		return diags
+	}
+
+	plan.SecretsLastChanged = timeValueOrNull(latestSecretChange(describeSecretSources(client, secretSources)))
+
+	return diags
+}
+
+// secretsRefreshDue reports whether the secrets have to be fetched, which is
+// the case unless the last fetch is more recent than interval.
+func secretsRefreshDue(force bool, interval string, fetchedAt string, now time.Time) bool {
+	if force || interval == "" {
+		return true
+	}
+
+	refreshInterval, err := time.ParseDuration(interval)
 	if err != nil {
-		return diag.FromErr(err)
+		return true
+	}
+
+	lastFetched, err := time.Parse(time.RFC3339, fetchedAt)
+	if err != nil {
+		return true
+	}
+
+	return !now.Before(lastFetched.Add(refreshInterval))
+}
+
+// preflightLambdaLayerPlan runs the preflight once the sources are known.
+// Malformed ARNs are left to the validation that follows.
+func preflightLambdaLayerPlan(ctx context.Context, client *AWSClient, plan lambdaLayerResourceModel) diag.Diagnostics {
+	for _, v := range []attr.Value{plan.LayerName, plan.SecretsArns, plan.SecretSource, plan.ReplicaRegions} {
+		if !isFullyKnown(ctx, v) {
+			return nil
+		}
 	}
 
-	licenseFilesRaw := d.Get("license_files").([]interface{})
-	licenseFiles := make([]string, len(licenseFilesRaw))
-	for i, lf := range licenseFilesRaw {
-		licenseFiles[i] = lf.(string)
+	secretSources, diags := expandLambdaLayerSecretSources(ctx, plan)
+	if diags.HasError() {
+		return diags
 	}
 
-	zipFile, err := CreateZipFile(d.Get("file_name").(string), []byte(content), licenseFiles)
+	// Invalid ARNs are reported when the secrets are fetched
+	if validateSecretSources(secretSources, client.Partition).HasError() {
+		return diags
+	}
+
+	diags.Append(preflightLambdaLayer(client, plan.LayerName.ValueString(), stringListValue(ctx, plan.ReplicaRegions), secretSources)...)
+
+	return diags
+}
+
+// layerVersionInputsChanged reports whether an argument that ends up in
+// PublishLayerVersion, or decides where it is called, differs from the
+// state. Unset and empty values are the same.
+func layerVersionInputsChanged(ctx context.Context, plan, state lambdaLayerResourceModel) bool {
+	for _, v := range []attr.Value{plan.LayerName, plan.YamlConfig, plan.Config, plan.FileName, plan.Description, plan.LicenseInfo, plan.ReplicaRegions,
+		plan.CompatibleRuntimes, plan.CompatibleArchitectures, plan.LicenseFiles, plan.EffectiveEnvsMap, plan.EnvsMapWOVersion, plan.Triggers} {
+		if !isFullyKnown(ctx, v) {
+			return true
+		}
+	}
+
+	return plan.LayerName.ValueString() != state.LayerName.ValueString() ||
+		layerConfigVarsChanged(ctx, plan, state) ||
+		plan.FileName.ValueString() != state.FileName.ValueString() ||
+		plan.Description.ValueString() != state.Description.ValueString() ||
+		plan.LicenseInfo.ValueString() != state.LicenseInfo.ValueString() ||
+		plan.EnvsMapWOVersion.ValueInt64() != state.EnvsMapWOVersion.ValueInt64() ||
+		!slices.Equal(stringListValue(ctx, plan.ReplicaRegions), stringListValue(ctx, state.ReplicaRegions)) ||
+		!slices.Equal(stringListValue(ctx, plan.CompatibleRuntimes), stringListValue(ctx, state.CompatibleRuntimes)) ||
+		!slices.Equal(stringListValue(ctx, plan.CompatibleArchitectures), stringListValue(ctx, state.CompatibleArchitectures)) ||
+		!slices.Equal(stringListValue(ctx, plan.LicenseFiles), stringListValue(ctx, state.LicenseFiles)) ||
+		!maps.Equal(stringMapValue(ctx, plan.EffectiveEnvsMap), stringMapValue(ctx, stateEffectiveEnvsMap(state))) ||
+		!maps.Equal(stringMapValue(ctx, plan.Triggers), stringMapValue(ctx, state.Triggers))
+}
+
+// stateEffectiveEnvsMap returns the variables a layer version in the state was
+// published with. States written before effective_envs_map held the merged
+// variables in envs_map.
+func stateEffectiveEnvsMap(state lambdaLayerResourceModel) types.Map {
+	if state.EffectiveEnvsMap.IsNull() {
+		return state.EnvsMap
+	}
+
+	return state.EffectiveEnvsMap
+}
+
+// layerConfigVarsChanged compares the flattened variables rather than the
+// arguments, so that moving from yaml_config to the same data in config does
+// not publish a new version.
+func layerConfigVarsChanged(ctx context.Context, plan, state lambdaLayerResourceModel) bool {
+	planVars, err := layerConfigVars(ctx, plan.Config, plan.YamlConfig)
 	if err != nil {
-		return diag.FromErr(err)
+		return true
 	}
 
-	zipFileBytes, err := ReadZipFile(zipFile)
+	stateVars, err := layerConfigVars(ctx, state.Config, state.YamlConfig)
+	if err != nil {
+		return true
+	}
+
+	return !maps.Equal(planVars, stateVars)
+}
+
+func secretSourcesChanged(plan, state lambdaLayerResourceModel) bool {
+	return !plan.SecretsArns.Equal(state.SecretsArns) || !plan.SecretSource.Equal(state.SecretSource)
+}
+
+// fetchLambdaLayerSecretsHash validates the secret sources and hashes their
+// current values. The hash is unknown while a source is unknown.
+func fetchLambdaLayerSecretsHash(ctx context.Context, client *AWSClient, plan lambdaLayerResourceModel, arnsChanged bool) (types.String, diag.Diagnostics) {
+	if !isFullyKnown(ctx, plan.SecretsArns) || !isFullyKnown(ctx, plan.SecretSource) {
+		return types.StringUnknown(), nil
+	}
+
+	secretSources, diags := expandLambdaLayerSecretSources(ctx, plan)
+	if diags.HasError() {
+		return types.StringUnknown(), diags
+	}
+
+	diags.Append(validateSecretSources(secretSources, client.Partition)...)
+	if diags.HasError() {
+		return types.StringUnknown(), diags
+	}
+
+	fetchedSecretsHash, err := fetchSecrets(secretSources, client, arnsChanged, plan.TrackActualSecrets.ValueBool())
+	if err != nil {
+		diags.AddError("Failed to fetch secrets", err.Error())
+		return types.StringUnknown(), diags
+	}
+
+	return types.StringValue(fetchedSecretsHash), diags
+}
+
+// setLayerVersionUnknown marks the attributes that describe the published
+// layer version, which change every time a new version is published.
+func setLayerVersionUnknown(plan *lambdaLayerResourceModel, config lambdaLayerResourceModel, layerChanged bool) {
+	if layerChanged {
+		plan.ID = types.StringUnknown()
+		plan.LayerArn = types.StringUnknown()
+	}
+	plan.LayerID = types.StringUnknown()
+	plan.Version = types.Int64Unknown()
+	plan.CodeSha256 = types.StringUnknown()
+	plan.CodeSize = types.Int64Unknown()
+	plan.CreatedDate = types.StringUnknown()
+	plan.ReplicaLayerIDs = types.MapUnknown(types.StringType)
+	plan.VersionHistory = types.ListUnknown(versionHistoryEntryType)
+	plan.NeedUpdate = types.BoolValue(false)
+	plan.SecretsFetchedAt = types.StringUnknown()
+
+	// The secrets are read again when publishing and may have been rotated
+	// since the plan
+	if config.StoredSecretsHash.IsNull() {
+		plan.StoredSecretsHash = types.StringUnknown()
+	}
+}
+
+func copyLayerVersionAttributes(plan *lambdaLayerResourceModel, state lambdaLayerResourceModel) {
+	plan.ID = state.ID
+	plan.LayerArn = state.LayerArn
+	plan.LayerID = state.LayerID
+	plan.Version = state.Version
+	plan.CodeSha256 = state.CodeSha256
+	plan.CodeSize = state.CodeSize
+	plan.CreatedDate = state.CreatedDate
+	plan.ReplicaLayerIDs = state.ReplicaLayerIDs
+	plan.VersionHistory = state.VersionHistory
+	plan.StoredSecretsHash = state.StoredSecretsHash
+	plan.NeedUpdate = state.NeedUpdate
+	plan.SecretsFetchedAt = state.SecretsFetchedAt
+}
+
+// planPinnedVersion points the plan at a retained layer version.
+func planPinnedVersion(ctx context.Context, plan *lambdaLayerResourceModel, state lambdaLayerResourceModel) diag.Diagnostics {
+	copyLayerVersionAttributes(plan, state)
+
+	history, diags := versionHistoryValue(ctx, state.VersionHistory)
+	if diags.HasError() {
+		return diags
+	}
+
+	pinnedVersion := plan.PinnedVersion.ValueInt64()
+	entry, found := findVersionHistoryEntry(history, pinnedVersion)
+	if !found {
+		diags.AddAttributeError(path.Root("pinned_version"), "Invalid pinned_version",
+			fmt.Sprintf("pinned_version %d is not in version_history, raise history_size to retain more versions", pinnedVersion))
+		return diags
+	}
+
+	replicaLayerIDs, d := types.MapValueFrom(ctx, types.StringType, entry.ReplicaLayerIDs)
+	diags.Append(d...)
+
+	plan.LayerID = types.StringValue(entry.LayerID)
+	plan.Version = types.Int64Value(entry.Version)
+	plan.CodeSha256 = types.StringValue(entry.CodeSha256)
+	plan.CodeSize = types.Int64Value(entry.CodeSize)
+	plan.CreatedDate = types.StringValue(entry.CreatedDate)
+	plan.StoredSecretsHash = types.StringValue(entry.SecretsHash)
+	plan.ReplicaLayerIDs = replicaLayerIDs
+
+	return diags
+}
+
+// publishLambdaLayerVersion builds the archive from the planned arguments,
+// publishes it in the layer's region and every replica region, and records
+// the new version in the plan and in front of history.
+func publishLambdaLayerVersion(ctx context.Context, client *AWSClient, plan *lambdaLayerResourceModel, envsMapWO map[string]string, history []versionHistoryEntry) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	secretSources, d := expandLambdaLayerSecretSources(ctx, *plan)
+	diags.Append(d...)
+
+	var permissions []layerPermission
+	diags.Append(plan.Permission.ElementsAs(ctx, &permissions, true)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	// Write-only variables take precedence over envs_map
+	envsMap := stringMapValue(ctx, plan.EffectiveEnvsMap)
+	maps.Copy(envsMap, envsMapWO)
+
+	configVars, err := layerConfigVars(ctx, plan.Config, plan.YamlConfig)
 	if err != nil {
-		return diag.FromErr(err)
+		diags.AddError("Failed to process the layer configuration", err.Error())
+		return diags
 	}
 
-	var compatibleRuntimes []*string
-	if v, ok := d.GetOk("compatible_runtimes"); ok {
-		compatibleRuntimes = expandStringList(v.([]interface{}))
+	content, err, secretHash := createEnvFileContent(client, configVars, secretSources, envsMap, plan.TrackActualSecrets.ValueBool())
+	if err != nil {
+		diags.AddError("Failed to create the env file", err.Error())
+		return diags
+	}
+
+	zipFile, err := CreateZipFile(plan.FileName.ValueString(), []byte(content), stringListValue(ctx, plan.LicenseFiles))
+	if err != nil {
+		diags.AddError("Failed to create the layer archive", err.Error())
+		return diags
+	}
+
+	zipFileBytes, err := ReadZipFile(zipFile)
+	if err != nil {
+		diags.AddError("Failed to read the layer archive", err.Error())
+		return diags
 	}
 
 	input := &lambda.PublishLayerVersionInput{
-		LayerName:          aws.String(d.Get("layer_name").(string)),
-		CompatibleRuntimes: compatibleRuntimes,
+		LayerName:               aws.String(plan.LayerName.ValueString()),
+		CompatibleRuntimes:      expandStringList(stringListValue(ctx, plan.CompatibleRuntimes)),
+		CompatibleArchitectures: expandStringList(stringListValue(ctx, plan.CompatibleArchitectures)),
 		Content: &lambda.LayerVersionContentInput{
 			ZipFile: zipFileBytes,
 		},
 	}
 
-	lambdaSvc := lambda.New(sess)
-	output, err := lambdaSvc.PublishLayerVersion(input)
+	if v := plan.Description.ValueString(); v != "" {
+		input.Description = aws.String(v)
+	}
+
+	if v := plan.LicenseInfo.ValueString(); v != "" {
+		input.LicenseInfo = aws.String(v)
+	}
+
+	output, replicaLayerIds, err := publishLayerVersionInRegions(ctx, client, input, stringListValue(ctx, plan.ReplicaRegions), permissions)
 	if err != nil {
-		return diag.FromErr(err)
+		diags.AddError("Failed to publish layer version", err.Error())
+		return diags
+	}
+
+	entry := versionHistoryEntry{
+		Version:         aws.Int64Value(output.Version),
+		LayerID:         aws.StringValue(output.LayerVersionArn),
+		SecretsHash:     secretHash,
+		CreatedDate:     aws.StringValue(output.CreatedDate),
+		ReplicaLayerIDs: replicaLayerIds,
+	}
+	if output.Content != nil {
+		entry.CodeSha256 = aws.StringValue(output.Content.CodeSha256)
+		entry.CodeSize = aws.Int64Value(output.Content.CodeSize)
+	}
+	logger.Debug("DEBUG layer id", "value", entry.LayerID)
+
+	plan.ID = types.StringValue(aws.StringValue(output.LayerArn))
+	plan.LayerArn = types.StringValue(aws.StringValue(output.LayerArn))
+	plan.Region = types.StringValue(client.Region)
+	plan.StoredSecretsHash = types.StringValue(secretHash)
+	plan.NeedUpdate = types.BoolValue(false)
+	plan.SecretsFetchedAt = types.StringValue(formatTime(time.Now()))
+
+	history = appendVersionHistory(history, entry, int(plan.HistorySize.ValueInt64()))
+	diags.Append(setVersionHistoryEntryAttributes(ctx, plan, entry, history)...)
+
+	return diags
+}
+
+// publishLayerVersionInRegions publishes the identical archive in the layer's
+// region and every replica region, and shares each version. When a region
+// fails, the versions already published are deleted again, since a failed
+// publish leaves nothing in the state to track them.
+func publishLayerVersionInRegions(ctx context.Context, client *AWSClient, input *lambda.PublishLayerVersionInput, replicaRegions []string, permissions []layerPermission) (*lambda.PublishLayerVersionOutput, map[string]string, error) {
+	var output *lambda.PublishLayerVersionOutput
+	var published []*lambda.PublishLayerVersionOutput
+	replicaLayerIds := make(map[string]string)
+
+	for _, region := range append([]string{""}, replicaRegions...) {
+		lambdaSvc := client.LambdaConn(region)
+		regionName := aws.StringValue(lambdaSvc.Config.Region)
+
+		regionOutput, err := lambdaSvc.PublishLayerVersionWithContext(ctx, input)
+		if err != nil {
+			return nil, nil, rollbackLayerVersions(client, published, fmt.Errorf("failed to publish layer version in %s: %s", regionName, err))
+		}
+		published = append(published, regionOutput)
+
+		if err := addLayerVersionPermissions(lambdaSvc, aws.StringValue(regionOutput.LayerArn), aws.Int64Value(regionOutput.Version), permissions); err != nil {
+			return nil, nil, rollbackLayerVersions(client, published, fmt.Errorf("failed to share layer version in %s: %s", regionName, err))
+		}
+
+		if region == "" {
+			output = regionOutput
+			continue
+		}
+
+		replicaLayerIds[region] = aws.StringValue(regionOutput.LayerVersionArn)
+		logger.Debug("DEBUG replica layer id", "region", region, "value", aws.StringValue(regionOutput.LayerVersionArn))
+	}
+
+	return output, replicaLayerIds, nil
+}
+
+// rollbackLayerVersions deletes the layer versions of a publish that failed
+// with err, and names those that could not be deleted in the returned error.
+func rollbackLayerVersions(client *AWSClient, published []*lambda.PublishLayerVersionOutput, err error) error {
+	var leftOver []string
+
+	for _, output := range published {
+		layerVersionArn := aws.StringValue(output.LayerVersionArn)
+
+		layerVersion, deleteErr := arns.ParseLayerVersion(layerVersionArn)
+		if deleteErr == nil {
+			_, deleteErr = client.LambdaConn(layerVersion.Layer.Region).DeleteLayerVersion(&lambda.DeleteLayerVersionInput{
+				LayerName:     aws.String(layerVersion.Layer.String()),
+				VersionNumber: aws.Int64(layerVersion.Version),
+			})
+		}
+		if deleteErr != nil {
+			logger.Debug("rollbackLayerVersions failed to delete layer version", "layerVersionArn", layerVersionArn, "error", deleteErr)
+			leftOver = append(leftOver, layerVersionArn)
+		}
 	}
 
-	d.SetId(fmt.Sprintf("%s", *output.LayerArn))
-	d.Set("layer_id", fmt.Sprintf("%s:%d", *output.LayerArn, *output.Version))
-	logger.Debug("DEBUG layer id", "value", fmt.Sprintf("%s:%d", *output.LayerArn, *output.Version))
-	d.Set("stored_secrets_hash", secretHash)
+	if len(leftOver) > 0 {
+		return fmt.Errorf("%s, and the layer versions already published could not be deleted: %s", err, strings.Join(leftOver, ", "))
+	}
+
+	return err
+}
+
+// setVersionHistoryEntryAttributes points the model at a layer version and
+// records history.
+func setVersionHistoryEntryAttributes(ctx context.Context, m *lambdaLayerResourceModel, entry versionHistoryEntry, history []versionHistoryEntry) diag.Diagnostics {
+	var diags diag.Diagnostics
 
-	return resourceLambdaLayerRead(ctx, d, m)
+	replicaLayerIDs, d := types.MapValueFrom(ctx, types.StringType, entry.ReplicaLayerIDs)
+	diags.Append(d...)
+
+	versionHistory, d := types.ListValueFrom(ctx, versionHistoryEntryType, history)
+	diags.Append(d...)
+
+	m.LayerID = types.StringValue(entry.LayerID)
+	m.Version = types.Int64Value(entry.Version)
+	m.CodeSha256 = types.StringValue(entry.CodeSha256)
+	m.CodeSize = types.Int64Value(entry.CodeSize)
+	m.CreatedDate = types.StringValue(entry.CreatedDate)
+	m.ReplicaLayerIDs = replicaLayerIDs
+	m.VersionHistory = versionHistory
+
+	return diags
 }
 
-func resourceLambdaLayerRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	sess := m.(*session.Session)
-	secretsArns := d.Get("secrets_arns").([]interface{})
-	storedSecretsHash := d.Get("stored_secrets_hash").(string)
+// backfillLayerVersionAttributes describes the layer version of layer_id and
+// records it in version_history together with up to history_size earlier
+// versions of the layer. The secrets hash and replicas of the earlier versions
+// are not known, so they are recorded without them.
+func backfillLayerVersionAttributes(ctx context.Context, client *AWSClient, m *lambdaLayerResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if m.LayerID.ValueString() == "" {
+		return diags
+	}
 
-	fetchedSecretsHash, err := fetchSecrets(secretsArns, sess, false, d.Get("track_actual_secrets").(bool))
+	layerVersion, err := arns.ParseLayerVersion(m.LayerID.ValueString())
 	if err != nil {
-		return diag.FromErr(err)
+		diags.AddError("Invalid layer ID", err.Error())
+		return diags
 	}
+	layerArn := layerVersion.Layer.String()
+	client = client.WithRegion(layerVersion.Region)
 
-	if fetchedSecretsHash != storedSecretsHash {
-		d.Set("need_update", true)
+	details, err := getLambdaLayerVersion(client, layerArn, layerVersion.Version)
+	if err != nil {
+		diags.AddError("Failed to read layer version", err.Error())
+		return diags
 	}
 
-	return nil
+	versions, err := listLambdaLayerVersions(client, layerArn)
+	if err != nil {
+		diags.AddError("Failed to read layer version", fmt.Sprintf("failed to list versions: %s", err))
+		return diags
+	}
+
+	var history []versionHistoryEntry
+	for _, version := range versions {
+		if len(history) >= int(m.HistorySize.ValueInt64()) {
+			break
+		}
+		if version.Version >= layerVersion.Version {
+			continue
+		}
+
+		earlier, err := getLambdaLayerVersion(client, layerArn, version.Version)
+		if err != nil {
+			diags.AddError("Failed to read layer version", err.Error())
+			return diags
+		}
+		history = append(history, versionHistoryEntry{
+			Version:     earlier.Version,
+			LayerID:     earlier.LayerID,
+			CodeSha256:  earlier.CodeSha256,
+			CodeSize:    earlier.CodeSize,
+			CreatedDate: earlier.CreatedDate,
+		})
+	}
+
+	entry := versionHistoryEntry{
+		Version:         details.Version,
+		LayerID:         details.LayerID,
+		CodeSha256:      details.CodeSha256,
+		CodeSize:        details.CodeSize,
+		SecretsHash:     m.StoredSecretsHash.ValueString(),
+		CreatedDate:     details.CreatedDate,
+		ReplicaLayerIDs: stringMapValue(ctx, m.ReplicaLayerIDs),
+	}
+
+	m.LayerArn = types.StringValue(layerArn)
+	diags.Append(setVersionHistoryEntryAttributes(ctx, m, entry, appendVersionHistory(history, entry, len(history)))...)
+
+	return diags
 }
 
-func resourceLambdaLayerUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	logger.Debug("running resourceLambdaLayerUpdate...")
+// pruneLambdaLayerVersions deletes the layer versions that are not kept in
+// version_history before a new version is published. Without a history_size
+// this deletes every version, like Delete.
+func pruneLambdaLayerVersions(ctx context.Context, client *AWSClient, state lambdaLayerResourceModel, historySize int) diag.Diagnostics {
+	var diags diag.Diagnostics
 
-	sess := m.(*session.Session)
-	secretsArns := d.Get("secrets_arns").([]interface{})
-	storedSecretsHash := d.Get("stored_secrets_hash").(string)
-	logger.Debug("resourceLambdaLayerUpdate storedSecretsHash", "value", storedSecretsHash)
+	history, d := versionHistoryValue(ctx, state.VersionHistory)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
 
-	// Fetch secrets using the fetchSecrets function
-	fetchedSecretsHash, err := fetchSecrets(secretsArns, sess, d.HasChange("secrets_arns"), d.Get("track_actual_secrets").(bool))
+	layer, err := arns.ParseLayer(state.ID.ValueString())
 	if err != nil {
-		return diag.FromErr(err)
+		diags.AddError("Invalid resource ID", err.Error())
+		return diags
 	}
 
-	// Check if storedSecretsHash and fetchedSecrets are equal
-	secretsEqual := storedSecretsHash == fetchedSecretsHash
+	if err := deleteLambdaLayerVersions(layer, client, retainedLayerVersions(history, historySize, "")); err != nil {
+		diags.AddError("Failed to delete layer versions", err.Error())
+		return diags
+	}
 
-	if d.HasChanges("layer_name", "yaml_config", "secrets_arns", "envs_map", "file_name", "compatible_runtimes", "license_files") || !secretsEqual || d.Get("need_update").(bool) {
-		logger.Debug("resourceLambdaLayerUpdate HasChanges", "value", true)
-		skipDestroy := d.Get("skip_destroy").(bool)
-		logger.Debug("skipDestroy", "value", skipDestroy)
+	// The state holds the replicas that were actually published, even when
+	// the replica_regions list is being changed by this update
+	for region, replicaLayerId := range stringMapValue(ctx, state.ReplicaLayerIDs) {
+		replicaLayerVersion, err := arns.ParseLayerVersion(replicaLayerId)
+		if err != nil {
+			diags.AddError("Invalid replica layer ID", fmt.Sprintf("replica_layer_ids.%s: %s", region, err))
+			return diags
+		}
 
-		if !skipDestroy {
-			err := resourceLambdaLayerDelete(ctx, d, m)
+		retain := retainedLayerVersions(history, historySize, region)
+		if err := deleteLambdaLayerVersions(replicaLayerVersion.Layer, client, retain); err != nil {
+			diags.AddError("Failed to delete layer versions", fmt.Sprintf("failed to delete layer versions in %s: %s", region, err))
+			return diags
+		}
+	}
+
+	return diags
+}
+
+// updateLayerVersionPermissions replaces the permission statements of the
+// current layer version and its replicas when only the sharing changed.
+func updateLayerVersionPermissions(ctx context.Context, client *AWSClient, state, plan lambdaLayerResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var oldPermissions, newPermissions []layerPermission
+	diags.Append(state.Permission.ElementsAs(ctx, &oldPermissions, true)...)
+	diags.Append(plan.Permission.ElementsAs(ctx, &newPermissions, true)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	layerVersionArns := []string{plan.LayerID.ValueString()}
+	for _, replicaLayerId := range stringMapValue(ctx, plan.ReplicaLayerIDs) {
+		layerVersionArns = append(layerVersionArns, replicaLayerId)
+	}
+
+	for _, layerVersionArn := range layerVersionArns {
+		layerVersion, err := arns.ParseLayerVersion(layerVersionArn)
+		if err != nil {
+			diags.AddError("Invalid layer ID", err.Error())
+			return diags
+		}
+		layerArn, version := layerVersion.Layer.String(), layerVersion.Version
+
+		lambdaSvc := client.LambdaConn(layerVersion.Region)
+		if err := removeLayerVersionPermissions(lambdaSvc, layerArn, version, oldPermissions); err != nil {
+			diags.AddError("Failed to update layer version permissions", err.Error())
+			return diags
+		}
+		if err := addLayerVersionPermissions(lambdaSvc, layerArn, version, newPermissions); err != nil {
+			diags.AddError("Failed to update layer version permissions", err.Error())
+			return diags
+		}
+	}
+
+	return diags
+}
+
+// syncLayerVersionPermissions replaces the statements the provider added to
+// the layer version of the plan and its replicas with the permission blocks
+// of the plan. The statements are read from the version policy, since the
+// blocks in the state need not be the ones the version was shared with.
+func syncLayerVersionPermissions(ctx context.Context, client *AWSClient, plan lambdaLayerResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var permissions []layerPermission
+	diags.Append(plan.Permission.ElementsAs(ctx, &permissions, true)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	layerVersionArns := []string{plan.LayerID.ValueString()}
+	for _, replicaLayerId := range stringMapValue(ctx, plan.ReplicaLayerIDs) {
+		layerVersionArns = append(layerVersionArns, replicaLayerId)
+	}
+
+	for _, layerVersionArn := range layerVersionArns {
+		layerVersion, err := arns.ParseLayerVersion(layerVersionArn)
+		if err != nil {
+			diags.AddError("Invalid layer ID", err.Error())
+			return diags
+		}
+		layerArn, version := layerVersion.Layer.String(), layerVersion.Version
+
+		lambdaSvc := client.LambdaConn(layerVersion.Region)
+		statementIds, err := layerVersionStatementIds(lambdaSvc, layerArn, version)
+		if err != nil {
+			diags.AddError("Failed to update layer version permissions", err.Error())
+			return diags
+		}
+
+		for _, statementId := range statementIds {
+			if !strings.HasPrefix(statementId, layerPermissionStatementIdPrefix) {
+				continue
+			}
+
+			_, err := lambdaSvc.RemoveLayerVersionPermission(&lambda.RemoveLayerVersionPermissionInput{
+				LayerName:     aws.String(layerArn),
+				VersionNumber: aws.Int64(version),
+				StatementId:   aws.String(statementId),
+			})
 			if err != nil {
-				return err
+				diags.AddError("Failed to update layer version permissions", fmt.Sprintf("failed to remove layer version permission: %s", err))
+				return diags
 			}
 		}
 
-		return resourceLambdaLayerCreate(ctx, d, m)
+		if err := addLayerVersionPermissions(lambdaSvc, layerArn, version, permissions); err != nil {
+			diags.AddError("Failed to update layer version permissions", err.Error())
+			return diags
+		}
+	}
+
+	return diags
+}
+
+// layerVersionStatementIds lists the statement IDs of the policy of a layer
+// version, which has no policy until it is shared.
+func layerVersionStatementIds(lambdaSvc *lambda.Lambda, layerArn string, version int64) ([]string, error) {
+	output, err := lambdaSvc.GetLayerVersionPolicy(&lambda.GetLayerVersionPolicyInput{
+		LayerName:     aws.String(layerArn),
+		VersionNumber: aws.Int64(version),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layer version policy: %s", err)
+	}
+
+	var policy struct {
+		Statement []struct {
+			Sid string
+		}
+	}
+	if err := json.Unmarshal([]byte(aws.StringValue(output.Policy)), &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse layer version policy: %s", err)
+	}
+
+	statementIds := make([]string, 0, len(policy.Statement))
+	for _, statement := range policy.Statement {
+		statementIds = append(statementIds, statement.Sid)
+	}
+
+	return statementIds, nil
+}
+
+func addLayerVersionPermissions(lambdaSvc *lambda.Lambda, layerArn string, version int64, permissions []layerPermission) error {
+	for i, permission := range permissions {
+		input := &lambda.AddLayerVersionPermissionInput{
+			LayerName:     aws.String(layerArn),
+			VersionNumber: aws.Int64(version),
+			StatementId:   aws.String(layerPermissionStatementId(i)),
+			Action:        aws.String(permission.Action),
+			Principal:     aws.String(permission.Principal),
+		}
+		if permission.OrganizationID != "" {
+			input.OrganizationId = aws.String(permission.OrganizationID)
+		}
+
+		logger.Debug("addLayerVersionPermissions", "layerArn", layerArn, "version", version, "principal", permission.Principal)
+		if _, err := lambdaSvc.AddLayerVersionPermission(input); err != nil {
+			return fmt.Errorf("failed to add layer version permission for %s: %s", permission.Principal, err)
+		}
 	}
 
 	return nil
 }
 
-func deleteLambdaLayerVersions(layerARN string, sess *session.Session) error {
-    lambdaSvc := lambda.New(sess)
-    layerName := extractLayerName(layerARN)
-	logger.Debug("deleteLambdaLayerVersions", "layerARN", layerARN)
-	logger.Debug("deleteLambdaLayerVersions", "layerName", layerName)
+func removeLayerVersionPermissions(lambdaSvc *lambda.Lambda, layerArn string, version int64, permissions []layerPermission) error {
+	for i := range permissions {
+		_, err := lambdaSvc.RemoveLayerVersionPermission(&lambda.RemoveLayerVersionPermissionInput{
+			LayerName:     aws.String(layerArn),
+			VersionNumber: aws.Int64(version),
+			StatementId:   aws.String(layerPermissionStatementId(i)),
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove layer version permission: %s", err)
+		}
+	}
 
-    listLayerVersionsOutput, err := lambdaSvc.ListLayerVersions(&lambda.ListLayerVersionsInput{
-        LayerName: aws.String(layerName),
-    })
-    if err != nil {
-        return err
-    }
+	return nil
+}
 
-    logger.Debug("deleteLambdaLayerVersions", "listLayerVersionsOutput", fmt.Sprintf("%+v", listLayerVersionsOutput))
+// layerPermissionStatementIdPrefix marks the layer version policy statements
+// managed by the provider.
+const layerPermissionStatementIdPrefix = "awsenvsecretlayer-"
 
-    for _, layerVersion := range listLayerVersionsOutput.LayerVersions {
-		logger.Debug("deleteLambdaLayerVersions", "layerVersion", layerVersion)
-        _, err = lambdaSvc.DeleteLayerVersion(&lambda.DeleteLayerVersionInput{
-            LayerName:     aws.String(layerName),
-            VersionNumber: layerVersion.Version,
-        })
-        if err != nil {
-            return err
-        }
-    }
-
-    return nil
-}
-
-func extractLayerName(layerARN string) string {
-	logger.Debug("extractLayerName", "layerARN", layerARN)
-    arnParts := strings.Split(layerARN, ":")
-    if len(arnParts) < 7 {
-        logger.Error("Invalid Lambda Layer ARN", "ARN", layerARN)
-        return ""
-    }
-    layerName := arnParts[6]
-    logger.Debug("extractLayerName", "layerName", layerName)
-    return layerName
-}
-
-func resourceLambdaLayerCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
-	sess := meta.(*session.Session)
-	storedSecretsHash := diff.Get("stored_secrets_hash").(string)
-	secretsArns := diff.Get("secrets_arns").([]interface{})
+func layerPermissionStatementId(index int) string {
+	return fmt.Sprintf("%s%d", layerPermissionStatementIdPrefix, index)
+}
 
-	// Fetch secrets hash using the fetchSecrets function
-	fetchedSecretsHash, err := fetchSecrets(secretsArns, sess, diff.HasChange("secrets_arns"), diff.Get("track_actual_secrets").(bool))
+// deleteLambdaLayerVersions deletes the versions of the layer in the layer's
+// own region, except for those in retainVersions.
+func deleteLambdaLayerVersions(layer arns.Layer, client *AWSClient, retainVersions map[int64]bool) error {
+	lambdaSvc := client.LambdaConn(layer.Region)
+	logger.Debug("deleteLambdaLayerVersions", "layerARN", layer.String())
+
+	listLayerVersionsOutput, err := lambdaSvc.ListLayerVersions(&lambda.ListLayerVersionsInput{
+		LayerName: aws.String(layer.Name),
+	})
 	if err != nil {
 		return err
 	}
 
-	logger.Debug("resourceLambdaLayerCustomizeDiff fetchedSecretsHash", "value", fetchedSecretsHash)
-	logger.Debug("resourceLambdaLayerCustomizeDiff storedSecretsHash", "value", storedSecretsHash)
+	logger.Debug("deleteLambdaLayerVersions", "listLayerVersionsOutput", fmt.Sprintf("%+v", listLayerVersionsOutput))
 
-	// Set new stored_secrets_hash if fetchedSecretsHash is different from storedSecretsHash
-	if fetchedSecretsHash != storedSecretsHash {
-		if err := diff.SetNew("stored_secrets_hash", fetchedSecretsHash); err != nil {
-			return err
+	for _, layerVersion := range listLayerVersionsOutput.LayerVersions {
+		logger.Debug("deleteLambdaLayerVersions", "layerVersion", layerVersion)
+		if retainVersions[aws.Int64Value(layerVersion.Version)] {
+			continue
 		}
-
-		// Mark a field to be recomputed
-		if err := diff.SetNewComputed("layer_id"); err != nil {
+		_, err = lambdaSvc.DeleteLayerVersion(&lambda.DeleteLayerVersionInput{
+			LayerName:     aws.String(layer.Name),
+			VersionNumber: layerVersion.Version,
+		})
+		if err != nil {
 			return err
 		}
-		
 	}
 
-	if diff.HasChanges("layer_name", "yaml_config", "envs_map", "file_name", "compatible_runtimes", "license_files") {
-        if err := diff.SetNewComputed("layer_id"); err != nil {
-            return err
-        }
-    }
-
 	return nil
 }
 
-func resourceLambdaLayerDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-    sess := m.(*session.Session)
-    layerARN := d.Id()
-	logger.Debug("resourceLambdaLayerDelete", "layerARN", layerARN)
+func expandLambdaLayerSecretSources(ctx context.Context, m lambdaLayerResourceModel) ([]secretSource, diag.Diagnostics) {
+	var secretSources []secretSourceModel
+	diags := m.SecretSource.ElementsAs(ctx, &secretSources, true)
 
-    err := deleteLambdaLayerVersions(layerARN, sess)
-    if err != nil {
-        return diag.FromErr(err)
-    }
+	sources := expandSecretSources(stringListValue(ctx, m.SecretsArns), secretSources)
+	for i := range sources {
+		sources[i].PreferLocalReplica = m.PreferLocalReplicas.ValueBool()
+	}
 
-    return nil
+	return sources, diags
 }
 
-func expandStringList(lst []interface{}) []*string {
-	if len(lst) == 0 {
-		return nil
-	}
+func versionHistoryValue(ctx context.Context, v types.List) ([]versionHistoryEntry, diag.Diagnostics) {
+	var history []versionHistoryEntry
+	diags := v.ElementsAs(ctx, &history, true)
 
-	strings := make([]*string, len(lst))
-	for i, v := range lst {
-		strings[i] = aws.String(v.(string))
-	}
+	return history, diags
+}
 
-	return strings
+// stringListValue returns the elements of a list of strings, treating null
+// and unknown values as empty.
+func stringListValue(ctx context.Context, v types.List) []string {
+	var result []string
+	v.ElementsAs(ctx, &result, true)
+
+	return result
+}
+
+// stringMapValue returns the elements of a map of strings, treating null
+// and unknown values as empty.
+func stringMapValue(ctx context.Context, v types.Map) map[string]string {
+	result := make(map[string]string)
+	v.ElementsAs(ctx, &result, true)
+
+	return result
+}
+
+func isFullyKnown(ctx context.Context, v attr.Value) bool {
+	tfValue, err := v.ToTerraformValue(ctx)
+
+	return err == nil && tfValue.IsFullyKnown()
 }
 
-// Function to convert map to .env format
-func mapToEnvFormat(envsMap map[string]interface{}) string {
+// Function to convert map to .env format, sorted by key so that the same
+// variables always render the same content
+func mapToEnvFormat(envsMap map[string]string) string {
 	var envBuilder strings.Builder
 
-	for k, v := range envsMap {
-		envBuilder.WriteString(fmt.Sprintf("%s=%s\n", k, v))
+	for _, k := range slices.Sorted(maps.Keys(envsMap)) {
+		envBuilder.WriteString(fmt.Sprintf("%s=%s\n", k, envsMap[k]))
 	}
 
 	return envBuilder.String()
 }
 
-func createEnvFileContent(d *schema.ResourceData, sess *session.Session) (string, error, string) {
-	yamlConfig := d.Get("yaml_config").(string)
-	secretsArns := d.Get("secrets_arns").([]interface{})
-	envsMap := d.Get("envs_map").(map[string]interface{})
-
-	mergedVars, err := processYamlConfig(yamlConfig)
+func createEnvFileContent(client *AWSClient, configVars map[string]string, secretSources []secretSource, envsMap map[string]string, trackActualSecrets bool) (string, error, string) {
+	_, envFileContent, err := renderLayerEnv(client, configVars, secretSources, envsMap)
 	if err != nil {
 		return "", err, ""
 	}
 
-	// Fetching secrets from AWS Secrets Manager
-	secretsMgr := secretsmanager.New(sess)
+	// Fetch secrets hash using the fetchSecrets function
+	fetchedSecretsHash, err := fetchSecrets(secretSources, client, false, trackActualSecrets)
+	if err != nil {
+		return "", fmt.Errorf("failed to get fetchedSecretsHash: %s", err), ""
+	}
+
+	logger.Debug("createEnvFileContent fetchedSecretsHash", "value", fetchedSecretsHash)
+
+	return envFileContent, nil, fetchedSecretsHash
+}
 
-	for _, secretArn := range secretsArns {
-		input := &secretsmanager.GetSecretValueInput{
-			SecretId: aws.String(secretArn.(string)),
+// renderLayerEnv renders the .env file of a layer, in which envsMap is
+// written after the config and secret variables. It also returns the
+// variables as a .env loader sees them, with envsMap taking precedence.
+func renderLayerEnv(client *AWSClient, configVars map[string]string, secretSources []secretSource, envsMap map[string]string) (map[string]string, string, error) {
+	mergedVars, err := mergeSecretVars(client, configVars, secretSources)
+	if err != nil {
+		return nil, "", err
+	}
+
+	content := mapToEnvFormat(mergedVars) + mapToEnvFormat(envsMap)
+	maps.Copy(mergedVars, envsMap)
+
+	return mergedVars, content, nil
+}
+
+// mergeSecretVars reads every secret and sets its variables over the config
+// variables.
+func mergeSecretVars(client *AWSClient, configVars map[string]string, secretSources []secretSource) (map[string]string, error) {
+	mergedVars := maps.Clone(configVars)
+
+	// Fetching secrets from AWS Secrets Manager
+	for _, source := range secretSources {
+		result, err := getSecretValue(client, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secret value: %s", err)
 		}
 
-		result, err := secretsMgr.GetSecretValue(input)
+		secretVars, err := secretJSONVars(result)
 		if err != nil {
-			return "", fmt.Errorf("failed to get secret value: %s", err), ""
+			return nil, err
 		}
+		maps.Copy(mergedVars, secretVars)
+	}
+
+	return mergedVars, nil
+}
 
-		var secretVars map[string]string
-		err = json.Unmarshal([]byte(*result.SecretString), &secretVars)
+// getSecretValue reads a secret in its own region, unless the source sets
+// one. A source that prefers a local replica first tries the replica in the
+// client region and only falls back to its own region when there is none.
+func getSecretValue(client *AWSClient, source secretSource) (*secretsmanager.GetSecretValueOutput, error) {
+	if replicaID, ok := localReplicaSecretID(source.Arn, client.Region); ok && source.PreferLocalReplica && source.Region == "" {
+		svc, err := client.SecretsManagerConnForRole(source.RoleArn, client.Region)
 		if err != nil {
-			return "", fmt.Errorf("failed to unmarshal secret JSON: %s", err), ""
+			return nil, err
 		}
 
-		for k, v := range secretVars {
-			mergedVars[k] = v
+		result, err := svc.GetSecretValue(&secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(replicaID),
+		})
+		if err == nil {
+			logger.Debug("getSecretValue using region-local replica", "secret", source.Arn, "replica", replicaID)
+			return result, nil
+		}
+
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != secretsmanager.ErrCodeResourceNotFoundException {
+			return nil, err
 		}
 	}
 
-	envFileContent := ""
-	for k, v := range mergedVars {
-		envFileContent += fmt.Sprintf("%s=%v\n", k, v)
+	svc, err := client.SecretsManagerConnForRole(source.RoleArn, source.region())
+	if err != nil {
+		return nil, err
 	}
 
-	envFileContent += mapToEnvFormat(envsMap)
+	return svc.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(source.Arn),
+	})
+}
 
-	// Fetch secrets hash using the fetchSecrets function
-	fetchedSecretsHash, err := fetchSecrets(secretsArns, sess, false, d.Get("track_actual_secrets").(bool))
+// describeSecret describes a secret in its own region, unless the source
+// sets one, since DescribeSecret has no replica fallback.
+func describeSecret(client *AWSClient, source secretSource) (*secretsmanager.DescribeSecretOutput, error) {
+	svc, err := client.SecretsManagerConnForRole(source.RoleArn, source.region())
 	if err != nil {
-		return "", fmt.Errorf("failed to get fetchedSecretsHash: %s", err), ""
+		return nil, err
 	}
 
-	logger.Debug("createEnvFileContent fetchedSecretsHash", "value", fetchedSecretsHash)
-
-	return envFileContent, nil, fetchedSecretsHash
+	return svc.DescribeSecret(&secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(source.Arn),
+	})
 }
 
 func isJSON(s string) bool {
-    var js map[string]interface{}
-    return json.Unmarshal([]byte(s), &js) == nil
-}
-
-func fetchSecrets(secretsArns []interface{}, sess *session.Session, arnsChanged bool, trackActualSecrets bool) (string, error) {
-	if !trackActualSecrets && !arnsChanged && len(secretsArns) == 0 {
-        logger.Debug("secrets_arns changed to empty list, skipping secrets fetching")
-        return "", nil
-    }
-
-    svc := secretsmanager.New(sess)
-    fetchedSecrets := make(map[string]string)
-
-    for _, secretArn := range secretsArns {
-        input := &secretsmanager.GetSecretValueInput{
-            SecretId: aws.String(secretArn.(string)),
-        }
-
-        result, err := svc.GetSecretValue(input)
-        if err != nil {
-            return "", fmt.Errorf("failed to fetch secret: %s, %s", secretArn, err)
-        }
-
-        secretString := aws.StringValue(result.SecretString)
-        if isJSON(secretString) {
-            var secretVars map[string]string
-            err = json.Unmarshal([]byte(secretString), &secretVars)
-            if err != nil {
-                return "", fmt.Errorf("failed to unmarshal secret JSON: %s", err)
-            }
-            for k, v := range secretVars {
-                fetchedSecrets[k] = v
-            }
-        } else {
-            fetchedSecrets[aws.StringValue(result.Name)] = secretString
-        }
-    }
-
-    fetchedSecretsHash := computeSecretsHash(fetchedSecrets)
-    return fetchedSecretsHash, nil
+	var js map[string]interface{}
+	return json.Unmarshal([]byte(s), &js) == nil
+}
+
+func fetchSecrets(secretSources []secretSource, client *AWSClient, arnsChanged bool, trackActualSecrets bool) (string, error) {
+	if !trackActualSecrets && !arnsChanged && len(secretSources) == 0 {
+		logger.Debug("secrets_arns changed to empty list, skipping secrets fetching")
+		return "", nil
+	}
+
+	fetchedSecrets, err := fetchSecretValues(secretSources, client)
+	if err != nil {
+		return "", err
+	}
+
+	fetchedSecretsHash := computeSecretsHash(fetchedSecrets)
+	return fetchedSecretsHash, nil
+}
+
+// fetchSecretValues reads the variables of every source. A JSON secret
+// contributes its keys, any other secret its name.
+func fetchSecretValues(secretSources []secretSource, client *AWSClient) (map[string]string, error) {
+	fetchedSecrets := make(map[string]string)
+
+	for _, source := range secretSources {
+		result, err := getSecretValue(client, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch secret: %s, %s", source.Arn, err)
+		}
+
+		secretVars, err := secretValueVars(result)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(fetchedSecrets, secretVars)
+	}
+
+	return fetchedSecrets, nil
+}
+
+// secretValueVars returns the keys of a JSON secret, or the secret under its
+// name otherwise, which is what the secrets hash is computed from.
+func secretValueVars(result *secretsmanager.GetSecretValueOutput) (map[string]string, error) {
+	secretString := aws.StringValue(result.SecretString)
+	if !isJSON(secretString) {
+		return map[string]string{aws.StringValue(result.Name): secretString}, nil
+	}
+
+	var secretVars map[string]string
+	if err := json.Unmarshal([]byte(secretString), &secretVars); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secret JSON: %s", err)
+	}
+
+	return secretVars, nil
+}
+
+// secretJSONVars decodes a secret the way it is written to a layer, which
+// only takes a JSON object of string values.
+func secretJSONVars(result *secretsmanager.GetSecretValueOutput) (map[string]string, error) {
+	if result.SecretString == nil {
+		return nil, fmt.Errorf("secret %s has no string value, binary secrets are not supported", aws.StringValue(result.ARN))
+	}
+
+	var secretVars map[string]string
+	if err := json.Unmarshal([]byte(*result.SecretString), &secretVars); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secret JSON: %s", err)
+	}
+
+	return secretVars, nil
+}
+
+func expandStringList(lst []string) []*string {
+	if len(lst) == 0 {
+		return nil
+	}
+
+	return aws.StringSlice(lst)
+}
+
+func expandStringValueList(lst []interface{}) []string {
+	strings := make([]string, 0, len(lst))
+	for _, v := range lst {
+		strings = append(strings, v.(string))
+	}
+
+	return strings
 }
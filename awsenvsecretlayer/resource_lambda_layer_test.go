@@ -0,0 +1,337 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayerVersionInputsChanged(t *testing.T) {
+	ctx := context.Background()
+
+	state := lambdaLayerResourceModel{
+		LayerName:        types.StringValue("example"),
+		YamlConfig:       types.StringValue(`{"name": "test"}`),
+		Config:           types.DynamicNull(),
+		FileName:         types.StringValue("example.env"),
+		Description:      types.StringNull(),
+		LicenseInfo:      types.StringNull(),
+		ReplicaRegions:   types.ListNull(types.StringType),
+		LicenseFiles:     types.ListNull(types.StringType),
+		EnvsMap:          types.MapNull(types.StringType),
+		EffectiveEnvsMap: types.MapNull(types.StringType),
+		EnvsMapWOVersion: types.Int64Null(),
+		Triggers:         types.MapNull(types.StringType),
+
+		CompatibleRuntimes:      types.ListNull(types.StringType),
+		CompatibleArchitectures: types.ListNull(types.StringType),
+	}
+	assert.False(t, layerVersionInputsChanged(ctx, state, state))
+
+	// The same data moved from yaml_config to config
+	plan := state
+	plan.YamlConfig = types.StringValue("")
+	plan.Config = types.DynamicValue(types.ObjectValueMust(
+		map[string]attr.Type{"name": types.StringType},
+		map[string]attr.Value{"name": types.StringValue("test")},
+	))
+	assert.False(t, layerVersionInputsChanged(ctx, plan, state))
+
+	plan = state
+	plan.Triggers = types.MapValueMust(types.StringType, map[string]attr.Value{"kms_key": types.StringValue("v2")})
+	assert.True(t, layerVersionInputsChanged(ctx, plan, state))
+
+	plan.Triggers = types.MapUnknown(types.StringType)
+	assert.True(t, layerVersionInputsChanged(ctx, plan, state))
+
+	// Older states hold the merged variables in envs_map
+	merged := types.MapValueMust(types.StringType, map[string]attr.Value{"ORG_NAME": types.StringValue("acme"), "APP": types.StringValue("api")})
+	upgraded := state
+	upgraded.EnvsMap = merged
+	upgraded.EffectiveEnvsMap = types.MapNull(types.StringType)
+	plan = state
+	plan.EnvsMap = types.MapValueMust(types.StringType, map[string]attr.Value{"APP": types.StringValue("api")})
+	plan.EffectiveEnvsMap = merged
+	assert.False(t, layerVersionInputsChanged(ctx, plan, upgraded))
+
+	plan.EffectiveEnvsMap = types.MapValueMust(types.StringType, map[string]attr.Value{"APP": types.StringValue("api")})
+	assert.True(t, layerVersionInputsChanged(ctx, plan, upgraded))
+}
+
+func TestSecretsRefreshDue(t *testing.T) {
+	fetchedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fetched := formatTime(fetchedAt)
+
+	assert.True(t, secretsRefreshDue(false, "", fetched, fetchedAt), "no interval")
+	assert.False(t, secretsRefreshDue(false, "1h", fetched, fetchedAt.Add(59*time.Minute)))
+	assert.True(t, secretsRefreshDue(false, "1h", fetched, fetchedAt.Add(time.Hour)))
+	assert.True(t, secretsRefreshDue(true, "1h", fetched, fetchedAt), "forced")
+	assert.True(t, secretsRefreshDue(false, "1h", "", fetchedAt), "never fetched")
+	assert.True(t, secretsRefreshDue(false, "soon", fetched, fetchedAt), "invalid interval")
+}
+
+func TestGetSecretValue(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&input)
+
+		// The credential scope of the signature names the region called
+		region := strings.Split(r.Header.Get("Authorization"), "/")[2]
+		calls = append(calls, region+" "+input.SecretId)
+
+		switch {
+		case region == "eu-central-1" && strings.Contains(input.SecretId, "unreplicated"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "ResourceNotFoundException", "message": "Secrets Manager can't find the specified secret."}`))
+		case region == "eu-central-1" && strings.Contains(input.SecretId, "denied"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "AccessDeniedException", "message": "not authorized"}`))
+		default:
+			w.Write([]byte(`{"ARN": "` + input.SecretId + `", "SecretString": "{\"region\": \"` + region + `\"}"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := testAWSClient("eu-central-1", server.URL)
+	secretArn := "arn:aws:secretsmanager:us-east-1:111111111111:secret:db-AbCdEf"
+
+	// A secret is read in the region of its ARN with a single call
+	_, err := getSecretValue(client, secretSource{Arn: secretArn})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"us-east-1 " + secretArn}, calls)
+
+	// An opted-in source reads the replica in the client region
+	calls = nil
+	_, err = getSecretValue(client, secretSource{Arn: secretArn, PreferLocalReplica: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"eu-central-1 arn:aws:secretsmanager:eu-central-1:111111111111:secret:db-AbCdEf"}, calls)
+
+	// and falls back to the secret's region when there is no replica
+	calls = nil
+	unreplicatedArn := "arn:aws:secretsmanager:us-east-1:111111111111:secret:unreplicated-AbCdEf"
+	_, err = getSecretValue(client, secretSource{Arn: unreplicatedArn, PreferLocalReplica: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"eu-central-1 arn:aws:secretsmanager:eu-central-1:111111111111:secret:unreplicated-AbCdEf",
+		"us-east-1 " + unreplicatedArn,
+	}, calls)
+
+	// but not when the replica cannot be read
+	_, err = getSecretValue(client, secretSource{Arn: "arn:aws:secretsmanager:us-east-1:111111111111:secret:denied-AbCdEf", PreferLocalReplica: true})
+	assert.ErrorContains(t, err, "AccessDeniedException")
+}
+
+func TestPublishLayerVersionInRegions(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region := strings.Split(r.Header.Get("Authorization"), "/")[2]
+
+		switch {
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, region+" "+r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case region == "eu-west-1":
+			w.Header().Set("X-Amzn-Errortype", "CodeStorageExceededException")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"Type": "User", "message": "code storage limit exceeded"}`))
+		default:
+			layerArn := "arn:aws:lambda:" + region + ":111111111111:layer:example"
+			w.Write([]byte(`{"LayerArn": "` + layerArn + `", "LayerVersionArn": "` + layerArn + `:3", "Version": 3}`))
+		}
+	}))
+	defer server.Close()
+
+	client := testAWSClient("us-east-1", server.URL)
+	input := &lambda.PublishLayerVersionInput{
+		LayerName: aws.String("example"),
+		Content:   &lambda.LayerVersionContentInput{ZipFile: []byte("zip")},
+	}
+
+	output, replicaLayerIds, err := publishLayerVersionInRegions(context.Background(), client, input, []string{"us-west-2"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:lambda:us-east-1:111111111111:layer:example:3", aws.StringValue(output.LayerVersionArn))
+	assert.Equal(t, map[string]string{"us-west-2": "arn:aws:lambda:us-west-2:111111111111:layer:example:3"}, replicaLayerIds)
+	assert.Empty(t, deleted)
+
+	// A failing replica region deletes the versions published before it
+	_, _, err = publishLayerVersionInRegions(context.Background(), client, input, []string{"us-west-2", "eu-west-1"}, nil)
+	assert.ErrorContains(t, err, "failed to publish layer version in eu-west-1")
+	assert.Equal(t, []string{
+		"us-east-1 /2018-10-31/layers/arn:aws:lambda:us-east-1:111111111111:layer:example/versions/3",
+		"us-west-2 /2018-10-31/layers/arn:aws:lambda:us-west-2:111111111111:layer:example/versions/3",
+	}, deleted)
+}
+
+func TestSecretJSONVars(t *testing.T) {
+	vars, err := secretJSONVars(&secretsmanager.GetSecretValueOutput{
+		Name:         aws.String("db/credentials"),
+		SecretString: aws.String(`{"username": "app", "password": "secret"}`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"username": "app", "password": "secret"}, vars)
+
+	// A plain secret has no variable name that could be written to the layer
+	_, err = secretJSONVars(&secretsmanager.GetSecretValueOutput{
+		Name:         aws.String("prod/db/password"),
+		SecretString: aws.String("secret"),
+	})
+	assert.Error(t, err)
+
+	_, err = secretJSONVars(&secretsmanager.GetSecretValueOutput{
+		Name:         aws.String("certificate"),
+		SecretBinary: []byte{0, 1, 2},
+	})
+	assert.Error(t, err)
+}
+
+func TestSyncLayerVersionPermissions(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region := strings.Split(r.Header.Get("Authorization"), "/")[2]
+		calls = append(calls, region+" "+r.Method+" "+r.URL.Path)
+
+		switch r.Method {
+		case http.MethodGet:
+			// The pinned version was shared with two accounts when it was published
+			policy := `{"Version": "2012-10-17", "Statement": [{"Sid": "awsenvsecretlayer-0"}, {"Sid": "awsenvsecretlayer-1"}, {"Sid": "manual"}]}`
+			output, _ := json.Marshal(map[string]string{"Policy": policy})
+			w.Write(output)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Statement": "{}", "RevisionId": "1"}`))
+		}
+	}))
+	defer server.Close()
+
+	permissionType := types.ObjectType{AttrTypes: map[string]attr.Type{
+		"principal":       types.StringType,
+		"organization_id": types.StringType,
+		"action":          types.StringType,
+	}}
+	plan := lambdaLayerResourceModel{
+		LayerID: types.StringValue("arn:aws:lambda:us-east-1:111111111111:layer:example:2"),
+		ReplicaLayerIDs: types.MapValueMust(types.StringType, map[string]attr.Value{
+			"us-west-2": types.StringValue("arn:aws:lambda:us-west-2:111111111111:layer:example:2"),
+		}),
+		Permission: types.ListValueMust(permissionType, []attr.Value{
+			types.ObjectValueMust(permissionType.AttrTypes, map[string]attr.Value{
+				"principal":       types.StringValue("222222222222"),
+				"organization_id": types.StringValue(""),
+				"action":          types.StringValue("lambda:GetLayerVersion"),
+			}),
+		}),
+	}
+
+	client := testAWSClient("us-east-1", server.URL)
+	diags := syncLayerVersionPermissions(context.Background(), client, plan)
+	assert.False(t, diags.HasError(), diags)
+
+	// The statements of the pinned version are replaced in every region,
+	// leaving those the provider did not add
+	for _, region := range []string{"us-east-1", "us-west-2"} {
+		policyPath := "/2018-10-31/layers/arn:aws:lambda:" + region + ":111111111111:layer:example/versions/2/policy"
+		assert.Contains(t, calls, region+" GET "+policyPath)
+		assert.Contains(t, calls, region+" DELETE "+policyPath+"/awsenvsecretlayer-0")
+		assert.Contains(t, calls, region+" DELETE "+policyPath+"/awsenvsecretlayer-1")
+		assert.NotContains(t, calls, region+" DELETE "+policyPath+"/manual")
+		assert.Contains(t, calls, region+" POST "+policyPath)
+	}
+	assert.Len(t, calls, 8)
+}
+
+func TestBackfillLayerVersionAttributes(t *testing.T) {
+	layerArn := "arn:aws:lambda:us-west-2:111111111111:layer:example"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region := strings.Split(r.Header.Get("Authorization"), "/")[2]
+		if region != "us-west-2" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"Type": "User", "message": "layer not found"}`))
+			return
+		}
+
+		if strings.HasSuffix(r.URL.Path, "/versions") {
+			w.Write([]byte(`{"LayerVersions": [
+				{"LayerVersionArn": "` + layerArn + `:4", "Version": 4},
+				{"LayerVersionArn": "` + layerArn + `:3", "Version": 3},
+				{"LayerVersionArn": "` + layerArn + `:2", "Version": 2},
+				{"LayerVersionArn": "` + layerArn + `:1", "Version": 1}
+			]}`))
+			return
+		}
+
+		version := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		w.Write([]byte(`{"LayerArn": "` + layerArn + `", "LayerVersionArn": "` + layerArn + `:` + version + `", "Version": ` + version + `,
+			"CreatedDate": "2024-01-0` + version + `T00:00:00.000+0000", "Content": {"CodeSha256": "sha-` + version + `", "CodeSize": 10` + version + `}}`))
+	}))
+	defer server.Close()
+
+	// A state written before the layer version attributes existed
+	state := lambdaLayerResourceModel{
+		ID:                types.StringValue(layerArn),
+		LayerID:           types.StringValue(layerArn + ":3"),
+		StoredSecretsHash: types.StringValue("hash"),
+		HistorySize:       types.Int64Value(1),
+		ReplicaLayerIDs: types.MapValueMust(types.StringType, map[string]attr.Value{
+			"eu-west-1": types.StringValue("arn:aws:lambda:eu-west-1:111111111111:layer:example:1"),
+		}),
+		LayerArn:       types.StringNull(),
+		Version:        types.Int64Null(),
+		CodeSha256:     types.StringNull(),
+		CodeSize:       types.Int64Null(),
+		CreatedDate:    types.StringNull(),
+		VersionHistory: types.ListNull(versionHistoryEntryType),
+	}
+
+	client := testAWSClient("us-east-1", server.URL)
+	diags := backfillLayerVersionAttributes(context.Background(), client, &state)
+	assert.False(t, diags.HasError(), diags)
+
+	assert.Equal(t, layerArn, state.LayerArn.ValueString())
+	assert.Equal(t, layerArn+":3", state.LayerID.ValueString())
+	assert.Equal(t, int64(3), state.Version.ValueInt64())
+	assert.Equal(t, "sha-3", state.CodeSha256.ValueString())
+	assert.Equal(t, int64(103), state.CodeSize.ValueInt64())
+	assert.Equal(t, "2024-01-03T00:00:00.000+0000", state.CreatedDate.ValueString())
+
+	// The history holds the current version and history_size earlier ones,
+	// so the earlier version can be pinned
+	history, diags := versionHistoryValue(context.Background(), state.VersionHistory)
+	assert.False(t, diags.HasError(), diags)
+	assert.Equal(t, []versionHistoryEntry{
+		{
+			Version:         3,
+			LayerID:         layerArn + ":3",
+			CodeSha256:      "sha-3",
+			CodeSize:        103,
+			SecretsHash:     "hash",
+			CreatedDate:     "2024-01-03T00:00:00.000+0000",
+			ReplicaLayerIDs: map[string]string{"eu-west-1": "arn:aws:lambda:eu-west-1:111111111111:layer:example:1"},
+		},
+		{
+			Version:     2,
+			LayerID:     layerArn + ":2",
+			CodeSha256:  "sha-2",
+			CodeSize:    102,
+			CreatedDate: "2024-01-02T00:00:00.000+0000",
+		},
+	}, history)
+
+	state.PinnedVersion = types.Int64Value(2)
+	plan := state
+	assert.False(t, planPinnedVersion(context.Background(), &plan, state).HasError())
+	assert.Equal(t, layerArn+":2", plan.LayerID.ValueString())
+}
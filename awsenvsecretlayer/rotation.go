@@ -0,0 +1,106 @@
+package awsenvsecretlayer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// secretStatus is the rotation and deletion state DescribeSecret reports for
+// a secret source.
+type secretStatus struct {
+	Source           secretSource
+	RotationEnabled  bool
+	LastRotatedDate  *time.Time
+	NextRotationDate *time.Time
+	LastChangedDate  *time.Time
+	DeletedDate      *time.Time
+}
+
+// describeSecretSources describes every source. Secrets that cannot be
+// described are left out, since reading a layer only needs GetSecretValue.
+func describeSecretSources(client *AWSClient, secretSources []secretSource) []secretStatus {
+	var statuses []secretStatus
+
+	for _, source := range secretSources {
+		output, err := describeSecret(client, source)
+		if err != nil {
+			logger.Debug("describeSecretSources skipping secret", "secret", source.Arn, "error", err)
+			continue
+		}
+
+		statuses = append(statuses, secretStatus{
+			Source:           source,
+			RotationEnabled:  aws.BoolValue(output.RotationEnabled),
+			LastRotatedDate:  output.LastRotatedDate,
+			NextRotationDate: output.NextRotationDate,
+			LastChangedDate:  output.LastChangedDate,
+			DeletedDate:      output.DeletedDate,
+		})
+	}
+
+	return statuses
+}
+
+// latestSecretChange returns the time the most recently changed or rotated
+// secret changed, or nil when no secret reports either.
+func latestSecretChange(statuses []secretStatus) *time.Time {
+	var latest *time.Time
+
+	for _, status := range statuses {
+		for _, t := range []*time.Time{status.LastChangedDate, status.LastRotatedDate} {
+			if t != nil && (latest == nil || t.After(*latest)) {
+				latest = t
+			}
+		}
+	}
+
+	return latest
+}
+
+// secretRotationWarnings warns about secrets that are overdue for rotation,
+// pending deletion, or that were rotated after builtAt, the creation of the
+// layer version that stays in place. builtAt is zero when a new version is
+// published.
+func secretRotationWarnings(statuses []secretStatus, builtAt time.Time, now time.Time) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, status := range statuses {
+		source := status.Source
+
+		if status.DeletedDate != nil {
+			diags.AddAttributeWarning(source.attributePath(), "Secret scheduled for deletion",
+				fmt.Sprintf("secret %s is scheduled for deletion on %s, after which the layer can no longer be published", source.Arn, formatTime(*status.DeletedDate)))
+		}
+
+		if status.RotationEnabled && status.NextRotationDate != nil && status.NextRotationDate.Before(now) {
+			diags.AddAttributeWarning(source.attributePath(), "Secret rotation overdue",
+				fmt.Sprintf("secret %s was due for rotation on %s", source.Arn, formatTime(*status.NextRotationDate)))
+		}
+
+		if !builtAt.IsZero() && status.LastRotatedDate != nil && status.LastRotatedDate.After(builtAt) {
+			diags.AddAttributeWarning(source.attributePath(), "Secret rotated after the layer version was built",
+				fmt.Sprintf("secret %s was rotated on %s, after the layer version was created on %s", source.Arn, formatTime(*status.LastRotatedDate), formatTime(builtAt)))
+		}
+	}
+
+	return diags
+}
+
+// parseLayerCreatedDate parses the CreatedDate Lambda reports for a layer
+// version, such as 2018-11-27T15:10:45.123+0000.
+func parseLayerCreatedDate(createdDate string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05.000-0700", time.RFC3339} {
+		if t, err := time.Parse(layout, createdDate); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unexpected layer version created date %q", createdDate)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,55 @@
+package awsenvsecretlayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatestSecretChange(t *testing.T) {
+	changed := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rotated := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, latestSecretChange(nil))
+	assert.Equal(t, &rotated, latestSecretChange([]secretStatus{
+		{LastChangedDate: &changed},
+		{LastChangedDate: &changed, LastRotatedDate: &rotated},
+	}))
+}
+
+func TestSecretRotationWarnings(t *testing.T) {
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	builtAt := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	rotated := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	overdue := time.Date(2026, 4, 30, 0, 0, 0, 0, time.UTC)
+	source := secretSource{Arn: "arn:aws:secretsmanager:us-east-1:111111111111:secret:db-AbCdEf", Attribute: "secrets_arns.0"}
+
+	statuses := []secretStatus{{
+		Source:           source,
+		RotationEnabled:  true,
+		LastRotatedDate:  &rotated,
+		NextRotationDate: &overdue,
+		DeletedDate:      &overdue,
+	}}
+
+	diags := secretRotationWarnings(statuses, builtAt, now)
+	assert.False(t, diags.HasError())
+	assert.Equal(t, 3, diags.WarningsCount())
+
+	// A new layer version picks up the rotation
+	assert.Equal(t, 2, secretRotationWarnings(statuses, time.Time{}, now).WarningsCount())
+
+	statuses[0].DeletedDate = nil
+	statuses[0].RotationEnabled = false
+	assert.Empty(t, secretRotationWarnings(statuses, time.Time{}, now))
+}
+
+func TestParseLayerCreatedDate(t *testing.T) {
+	created, err := parseLayerCreatedDate("2018-11-27T15:10:45.123+0000")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 11, 27, 15, 10, 45, 123000000, time.UTC), created.UTC())
+
+	_, err = parseLayerCreatedDate("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package awsenvsecretlayer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/internal/arns"
+)
+
+// secretSource is a secret to read into the layer, optionally through an
+// assumed role and a region other than the provider's.
+type secretSource struct {
+	Arn     string
+	RoleArn string
+	Region  string
+
+	// PreferLocalReplica reads the replica of the secret in the client
+	// region, when there is one, instead of the secret in its own region
+	PreferLocalReplica bool
+
+	// Attribute is the path of the ARN in the configuration, used in errors
+	Attribute string
+}
+
+// region returns the region to read the secret in, which is the region of
+// its ARN unless the source sets one.
+func (s secretSource) region() string {
+	if s.Region != "" {
+		return s.Region
+	}
+
+	return secretArnRegion(s.Arn)
+}
+
+// attributePath returns the path of the ARN in the configuration.
+func (s secretSource) attributePath() path.Path {
+	var p path.Path
+	for i, step := range strings.Split(s.Attribute, ".") {
+		if i == 0 {
+			p = path.Root(step)
+		} else if index, err := strconv.Atoi(step); err == nil {
+			p = p.AtListIndex(index)
+		} else {
+			p = p.AtName(step)
+		}
+	}
+
+	return p
+}
+
+// secretSourceModel is a secret_source block.
+type secretSourceModel struct {
+	Arn     string `tfsdk:"arn"`
+	RoleArn string `tfsdk:"role_arn"`
+	Region  string `tfsdk:"region"`
+}
+
+// expandSecretSources merges secrets_arns, which are read with the provider
+// credentials, with the secret_source blocks.
+func expandSecretSources(secretsArns []string, secretSources []secretSourceModel) []secretSource {
+	sources := make([]secretSource, 0, len(secretsArns)+len(secretSources))
+
+	for i, secretArn := range secretsArns {
+		sources = append(sources, secretSource{
+			Arn:       secretArn,
+			Attribute: fmt.Sprintf("secrets_arns.%d", i),
+		})
+	}
+
+	for i, source := range secretSources {
+		sources = append(sources, secretSource{
+			Arn:       source.Arn,
+			RoleArn:   source.RoleArn,
+			Region:    source.Region,
+			Attribute: fmt.Sprintf("secret_source.%d.arn", i),
+		})
+	}
+
+	return sources
+}
+
+// validateSecretSources checks at plan time that every secret ARN is a
+// well-formed Secrets Manager ARN in the partition of the provider, and
+// reports each invalid ARN on the argument it comes from.
+func validateSecretSources(secretSources []secretSource, partition string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, source := range secretSources {
+		secret, err := arns.ParseSecret(source.Arn)
+		if err != nil {
+			diags.AddAttributeError(source.attributePath(), "Invalid secret ARN", err.Error())
+			continue
+		}
+
+		if partition != "" && secret.Partition != partition {
+			diags.AddAttributeError(source.attributePath(), "Invalid secret ARN",
+				fmt.Sprintf("partition %q of %q does not match the provider partition %q", secret.Partition, source.Arn, partition))
+		}
+	}
+
+	return diags
+}
@@ -0,0 +1,58 @@
+package awsenvsecretlayer
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandSecretSources(t *testing.T) {
+	sources := expandSecretSources(
+		[]string{"arn:aws:secretsmanager:us-east-1:111111111111:secret:example1/env-1/123"},
+		[]secretSourceModel{{
+			Arn:     "arn:aws:secretsmanager:us-east-1:222222222222:secret:example2/secret/1233",
+			RoleArn: "arn:aws:iam::222222222222:role/secrets-reader",
+		}},
+	)
+
+	assert.Equal(t, []secretSource{
+		{
+			Arn:       "arn:aws:secretsmanager:us-east-1:111111111111:secret:example1/env-1/123",
+			Attribute: "secrets_arns.0",
+		},
+		{
+			Arn:       "arn:aws:secretsmanager:us-east-1:222222222222:secret:example2/secret/1233",
+			RoleArn:   "arn:aws:iam::222222222222:role/secrets-reader",
+			Attribute: "secret_source.0.arn",
+		},
+	}, sources)
+
+	assert.Equal(t, path.Root("secrets_arns").AtListIndex(0), sources[0].attributePath())
+	assert.Equal(t, path.Root("secret_source").AtListIndex(0).AtName("arn"), sources[1].attributePath())
+}
+
+func TestValidateSecretSources(t *testing.T) {
+	valid := []secretSource{{Arn: "arn:aws:secretsmanager:us-east-1:111111111111:secret:example1/env-1/123"}}
+	assert.False(t, validateSecretSources(valid, "aws").HasError())
+	assert.False(t, validateSecretSources(valid, "").HasError())
+	assert.True(t, validateSecretSources(valid, "aws-cn").HasError())
+
+	for _, secretArn := range []string{
+		"example1/env-1/123",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example-layer",
+		"arn:aws:secretsmanager::111111111111:secret:example1/env-1/123",
+	} {
+		assert.True(t, validateSecretSources([]secretSource{{Arn: secretArn}}, "aws").HasError(), secretArn)
+	}
+
+	// Every invalid ARN is reported on its own argument
+	diags := validateSecretSources(expandSecretSources([]string{
+		"arn:aws:secretsmanager:us-east-1:111111111111:secret:example1/env-1/123",
+		"example1/env-1/123",
+	}, []secretSourceModel{{Arn: "arn:aws-cn:secretsmanager:cn-north-1:111111111111:secret:example2"}}), "aws")
+	assert.Len(t, diags, 2)
+	assert.Equal(t, path.Root("secrets_arns").AtListIndex(1), diags[0].(diag.DiagnosticWithPath).Path())
+	assert.Equal(t, path.Root("secret_source").AtListIndex(0).AtName("arn"), diags[1].(diag.DiagnosticWithPath).Path())
+}
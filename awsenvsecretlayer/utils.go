@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/ghodss/yaml"
+	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/internal/arns"
 	"io"
 	"os"
 	"path/filepath"
@@ -14,10 +15,8 @@ import (
 )
 
 func processYamlConfig(yamlConfig string) (map[string]string, error) {
-	result := make(map[string]string)
-
 	if yamlConfig == "" {
-		return result, nil
+		return make(map[string]string), nil
 	}
 
 	var yamlData map[string]interface{}
@@ -26,34 +25,46 @@ func processYamlConfig(yamlConfig string) (map[string]string, error) {
 		return nil, err
 	}
 
-	for k, v := range yamlData {
-		flattenedMap := flatten("", k, v)
+	return flattenConfig(yamlData), nil
+}
+
+// flattenConfig joins nested keys with an underscore. Only string values end
+// up in the result.
+func flattenConfig(data map[string]interface{}) map[string]string {
+	return flattenConfigWithSeparator(data, "_")
+}
+
+func flattenConfigWithSeparator(data map[string]interface{}, sep string) map[string]string {
+	result := make(map[string]string)
+
+	for k, v := range data {
+		flattenedMap := flatten("", k, v, sep)
 		for fk, fv := range flattenedMap {
 			result[fk] = fv
 		}
 	}
 
-	return result, nil
+	return result
 }
 
-func flatten(prefix string, key string, value interface{}) map[string]string {
+func flatten(prefix string, key string, value interface{}, sep string) map[string]string {
 	result := make(map[string]string)
 
 	switch v := value.(type) {
 	case map[string]interface{}:
 		for k, subv := range v {
-			newKey := key + "_" + k
+			newKey := key + sep + k
 			if prefix != "" {
-				newKey = prefix + "_" + newKey
+				newKey = prefix + sep + newKey
 			}
-			subMap := flatten(key, newKey, subv)
+			subMap := flatten(key, newKey, subv, sep)
 			for k, v := range subMap {
 				result[k] = v
 			}
 		}
 	case string:
 		if prefix != "" {
-			key = prefix + "_" + key
+			key = prefix + sep + key
 		}
 		result[key] = v
 	}
@@ -147,3 +158,120 @@ func computeSecretsHash(secrets map[string]string) string {
 
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+// localReplicaSecretID rewrites a Secrets Manager ARN to point at the replica
+// of the same secret in region. It reports false when secretID is not a
+// secret ARN or already belongs to region.
+func localReplicaSecretID(secretID string, region string) (string, bool) {
+	secret, err := arns.ParseSecret(secretID)
+	if err != nil || region == "" || secret.Region == region {
+		return "", false
+	}
+
+	return secret.InRegion(region).String(), true
+}
+
+// secretArnRegion returns the region of a Secrets Manager ARN, or an empty
+// string when secretID is not a secret ARN.
+func secretArnRegion(secretID string) string {
+	secret, err := arns.ParseSecret(secretID)
+	if err != nil {
+		return ""
+	}
+
+	return secret.Region
+}
+
+// replaceLayerVersion swaps any version of the layer in layers for
+// layerVersionArn, keeping its position, or appends it when the layer is not
+// attached yet.
+func replaceLayerVersion(layers []string, layerVersionArn string) []string {
+	layerArn := layerArnOf(layerVersionArn)
+	result := make([]string, 0, len(layers)+1)
+	replaced := false
+
+	for _, layer := range layers {
+		if layerArn != "" && layerArnOf(layer) == layerArn {
+			if !replaced {
+				result = append(result, layerVersionArn)
+				replaced = true
+			}
+			continue
+		}
+		result = append(result, layer)
+	}
+
+	if !replaced {
+		result = append(result, layerVersionArn)
+	}
+
+	return result
+}
+
+// layerArnOf returns the layer ARN of a layer version ARN, or an empty string
+// when it cannot be parsed.
+func layerArnOf(layerVersionArn string) string {
+	layerVersion, err := arns.ParseLayerVersion(layerVersionArn)
+	if err != nil {
+		return ""
+	}
+
+	return layerVersion.Layer.String()
+}
+
+// removeLayer drops every version of the layer from layers.
+func removeLayer(layers []string, layerArn string) []string {
+	result := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		if layerArn != "" && layerArnOf(layer) == layerArn {
+			continue
+		}
+		result = append(result, layer)
+	}
+
+	return result
+}
+
+// appendVersionHistory puts entry in front of history, newest first, and keeps
+// at most historySize earlier versions.
+func appendVersionHistory(history []versionHistoryEntry, entry versionHistoryEntry, historySize int) []versionHistoryEntry {
+	if len(history) > historySize {
+		history = history[:historySize]
+	}
+
+	return append([]versionHistoryEntry{entry}, history...)
+}
+
+func findVersionHistoryEntry(history []versionHistoryEntry, version int64) (versionHistoryEntry, bool) {
+	for _, entry := range history {
+		if entry.Version == version {
+			return entry, true
+		}
+	}
+
+	return versionHistoryEntry{}, false
+}
+
+// retainedLayerVersions returns the versions that stay in history once a new
+// version is published. An empty region selects the primary layer versions,
+// any other region the replica versions published there.
+func retainedLayerVersions(history []versionHistoryEntry, historySize int, region string) map[int64]bool {
+	retain := make(map[int64]bool)
+
+	for i, entry := range history {
+		if i >= historySize {
+			break
+		}
+
+		layerVersionArn := entry.LayerID
+		if region != "" {
+			layerVersionArn = entry.ReplicaLayerIDs[region]
+		}
+
+		if layerVersion, err := arns.ParseLayerVersion(layerVersionArn); err == nil {
+			retain[layerVersion.Version] = true
+		}
+	}
+
+	return retain
+}
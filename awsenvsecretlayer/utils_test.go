@@ -1,6 +1,7 @@
 package awsenvsecretlayer
 
 import (
+	"fmt"
 	"testing"
 	"os"
 	"bytes"
@@ -110,3 +111,75 @@ func TestReadZipFile(t *testing.T) {
     assert.True(t, foundContent)
     assert.True(t, foundLicenseFile)
 }
+
+func TestLocalReplicaSecretID(t *testing.T) {
+	secretArn := "arn:aws:secretsmanager:us-east-1:111111111111:secret:example1/env-1/123"
+
+	replicaID, ok := localReplicaSecretID(secretArn, "eu-central-1")
+	assert.True(t, ok)
+	assert.Equal(t, "arn:aws:secretsmanager:eu-central-1:111111111111:secret:example1/env-1/123", replicaID)
+
+	_, ok = localReplicaSecretID(secretArn, "us-east-1")
+	assert.False(t, ok)
+
+	_, ok = localReplicaSecretID("example1/env-1", "eu-central-1")
+	assert.False(t, ok)
+}
+
+func TestReplaceLayerVersion(t *testing.T) {
+	layers := []string{
+		"arn:aws:lambda:us-east-1:111111111111:layer:other-layer:7",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example-layer:3",
+	}
+
+	result := replaceLayerVersion(layers, "arn:aws:lambda:us-east-1:111111111111:layer:example-layer:4")
+	assert.Equal(t, []string{
+		"arn:aws:lambda:us-east-1:111111111111:layer:other-layer:7",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example-layer:4",
+	}, result)
+
+	result = replaceLayerVersion(layers[:1], "arn:aws:lambda:us-east-1:111111111111:layer:example-layer:4")
+	assert.Equal(t, []string{
+		"arn:aws:lambda:us-east-1:111111111111:layer:other-layer:7",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example-layer:4",
+	}, result)
+}
+
+func TestRemoveLayer(t *testing.T) {
+	layers := []string{
+		"arn:aws:lambda:us-east-1:111111111111:layer:other-layer:7",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example-layer:3",
+	}
+
+	result := removeLayer(layers, "arn:aws:lambda:us-east-1:111111111111:layer:example-layer")
+	assert.Equal(t, []string{"arn:aws:lambda:us-east-1:111111111111:layer:other-layer:7"}, result)
+}
+
+func TestVersionHistory(t *testing.T) {
+	newEntry := func(version int64) versionHistoryEntry {
+		return versionHistoryEntry{
+			Version: version,
+			LayerID: fmt.Sprintf("arn:aws:lambda:us-east-1:111111111111:layer:example-layer:%d", version),
+			ReplicaLayerIDs: map[string]string{
+				"eu-west-1": fmt.Sprintf("arn:aws:lambda:eu-west-1:111111111111:layer:example-layer:%d", version+10),
+			},
+		}
+	}
+
+	var history []versionHistoryEntry
+	for version := int64(1); version <= 4; version++ {
+		history = appendVersionHistory(history, newEntry(version), 2)
+	}
+
+	assert.Len(t, history, 3)
+	assert.Equal(t, int64(4), history[0].Version)
+
+	_, found := findVersionHistoryEntry(history, 2)
+	assert.True(t, found)
+	_, found = findVersionHistoryEntry(history, 1)
+	assert.False(t, found)
+
+	assert.Equal(t, map[int64]bool{4: true, 3: true}, retainedLayerVersions(history, 2, ""))
+	assert.Equal(t, map[int64]bool{14: true}, retainedLayerVersions(history, 1, "eu-west-1"))
+	assert.Empty(t, retainedLayerVersions(history, 0, ""))
+}
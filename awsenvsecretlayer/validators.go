@@ -0,0 +1,149 @@
+package awsenvsecretlayer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/internal/arns"
+)
+
+func validateLayerVersionArn(v interface{}, path cty.Path) diag.Diagnostics {
+	if _, err := arns.ParseLayerVersion(v.(string)); err != nil {
+		return invalidArnDiagnostics(err, path)
+	}
+
+	return nil
+}
+
+// secretArnValidator checks that a string is a Secrets Manager secret ARN.
+type secretArnValidator struct{}
+
+func (v secretArnValidator) Description(ctx context.Context) string {
+	return "value must be a Secrets Manager secret ARN"
+}
+
+func (v secretArnValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v secretArnValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if _, err := arns.ParseSecret(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid ARN", err.Error())
+	}
+}
+
+// durationValidator checks that a string is a positive Go duration such as
+// 1h30m.
+type durationValidator struct{}
+
+func (v durationValidator) Description(ctx context.Context) string {
+	return "value must be a positive duration such as 30m or 1h"
+}
+
+func (v durationValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v durationValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	d, err := time.ParseDuration(req.ConfigValue.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid duration", err.Error())
+		return
+	}
+
+	if d <= 0 {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid duration",
+			fmt.Sprintf("%q must be greater than zero", req.ConfigValue.ValueString()))
+	}
+}
+
+func invalidArnDiagnostics(err error, path cty.Path) diag.Diagnostics {
+	return diag.Diagnostics{{
+		Severity:      diag.Error,
+		Summary:       "Invalid ARN",
+		Detail:        err.Error(),
+		AttributePath: path,
+	}}
+}
+
+func validateEndpoint(v interface{}, path cty.Path) diag.Diagnostics {
+	value := v.(string)
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Invalid endpoint",
+			Detail:        fmt.Sprintf("%q must be an absolute URL such as http://localhost:4566", value),
+			AttributePath: path,
+		}}
+	}
+
+	return nil
+}
+
+func validateDuration(v interface{}, path cty.Path) diag.Diagnostics {
+	value := v.(string)
+	if value == "" {
+		return nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Invalid duration",
+			Detail:        fmt.Sprintf("%q cannot be parsed as a duration: %s", value, err),
+			AttributePath: path,
+		}}
+	}
+
+	if duration < 15*time.Minute || duration > 12*time.Hour {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Invalid duration",
+			Detail:        fmt.Sprintf("%q must be between 15 minutes and 12 hours", value),
+			AttributePath: path,
+		}}
+	}
+
+	return nil
+}
+
+// layerConfigValidator checks that the layer config is an object or a map,
+// which is what its variables are flattened from.
+type layerConfigValidator struct{}
+
+func (v layerConfigValidator) Description(ctx context.Context) string {
+	return "value must be an object or a map"
+}
+
+func (v layerConfigValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v layerConfigValidator) ValidateDynamic(ctx context.Context, req validator.DynamicRequest, resp *validator.DynamicResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnderlyingValueNull() || !isFullyKnown(ctx, req.ConfigValue) {
+		return
+	}
+
+	if _, err := dynamicObjectValue(ctx, req.ConfigValue.UnderlyingValue()); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid config", err.Error())
+	}
+}
@@ -0,0 +1,189 @@
+// Package arns parses and validates the ARNs the provider works with: Lambda
+// layers, Lambda layer versions and Secrets Manager secrets, in the aws,
+// aws-cn and aws-us-gov partitions.
+package arns
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+// Partitions lists the partitions the provider supports and the prefix their
+// region names start with. The aws partition has no common prefix.
+var Partitions = map[string]string{
+	"aws":        "",
+	"aws-cn":     "cn-",
+	"aws-us-gov": "us-gov-",
+}
+
+var (
+	accountIDRegexp  = regexp.MustCompile(`^\d{12}$`)
+	regionRegexp     = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d$`)
+	layerNameRegexp  = regexp.MustCompile(`^[a-zA-Z0-9-_]{1,140}$`)
+	secretNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9/_+=.@-]{1,512}$`)
+
+	// Secrets Manager appends a hyphen and six random characters to names
+	secretSuffixRegexp = regexp.MustCompile(`-[a-zA-Z0-9]{6}$`)
+)
+
+// Layer is a Lambda layer ARN without a version.
+type Layer struct {
+	Partition string
+	Region    string
+	AccountID string
+	Name      string
+}
+
+func (l Layer) String() string {
+	return fmt.Sprintf("arn:%s:lambda:%s:%s:layer:%s", l.Partition, l.Region, l.AccountID, l.Name)
+}
+
+// LayerVersion is a Lambda layer ARN with a version number.
+type LayerVersion struct {
+	Layer
+	Version int64
+}
+
+func (l LayerVersion) String() string {
+	return fmt.Sprintf("%s:%d", l.Layer, l.Version)
+}
+
+// Secret is a Secrets Manager secret ARN.
+type Secret struct {
+	Partition string
+	Region    string
+	AccountID string
+	Name      string
+}
+
+func (s Secret) String() string {
+	return fmt.Sprintf("arn:%s:secretsmanager:%s:%s:secret:%s", s.Partition, s.Region, s.AccountID, s.Name)
+}
+
+// IsPartial reports whether the ARN lacks the random suffix Secrets Manager
+// appends to the secret name. A name that happens to end in a hyphen and six
+// characters cannot be told apart from a complete ARN.
+func (s Secret) IsPartial() bool {
+	return !secretSuffixRegexp.MatchString(s.Name)
+}
+
+// InRegion returns the ARN of the same secret in region, which is where
+// Secrets Manager keeps its replicas.
+func (s Secret) InRegion(region string) Secret {
+	s.Region = region
+	return s
+}
+
+// ParseLayer parses a Lambda layer ARN without a version.
+func ParseLayer(s string) (Layer, error) {
+	parsed, resource, err := parse(s, "lambda")
+	if err != nil {
+		return Layer{}, err
+	}
+
+	if len(resource) != 2 || resource[0] != "layer" {
+		return Layer{}, fmt.Errorf("%q is not a Lambda layer ARN, expected arn:<partition>:lambda:<region>:<account>:layer:<name>", s)
+	}
+
+	return newLayer(s, parsed, resource[1])
+}
+
+// ParseLayerVersion parses a Lambda layer version ARN.
+func ParseLayerVersion(s string) (LayerVersion, error) {
+	parsed, resource, err := parse(s, "lambda")
+	if err != nil {
+		return LayerVersion{}, err
+	}
+
+	if len(resource) != 3 || resource[0] != "layer" {
+		return LayerVersion{}, fmt.Errorf("%q is not a Lambda layer version ARN, expected arn:<partition>:lambda:<region>:<account>:layer:<name>:<version>", s)
+	}
+
+	layer, err := newLayer(s, parsed, resource[1])
+	if err != nil {
+		return LayerVersion{}, err
+	}
+
+	version, err := strconv.ParseInt(resource[2], 10, 64)
+	if err != nil || version < 1 {
+		return LayerVersion{}, fmt.Errorf("%q has an invalid layer version %q", s, resource[2])
+	}
+
+	return LayerVersion{Layer: layer, Version: version}, nil
+}
+
+// ParseSecret parses a Secrets Manager secret ARN.
+func ParseSecret(s string) (Secret, error) {
+	parsed, resource, err := parse(s, "secretsmanager")
+	if err != nil {
+		return Secret{}, err
+	}
+
+	if len(resource) != 2 || resource[0] != "secret" {
+		return Secret{}, fmt.Errorf("%q is not a Secrets Manager secret ARN, expected arn:<partition>:secretsmanager:<region>:<account>:secret:<name>", s)
+	}
+
+	if !secretNameRegexp.MatchString(resource[1]) {
+		return Secret{}, fmt.Errorf("%q has an invalid secret name %q", s, resource[1])
+	}
+
+	return Secret{
+		Partition: parsed.Partition,
+		Region:    parsed.Region,
+		AccountID: parsed.AccountID,
+		Name:      resource[1],
+	}, nil
+}
+
+// RegionPartition returns the partition a region name belongs to.
+func RegionPartition(region string) string {
+	for partition, prefix := range Partitions {
+		if prefix != "" && strings.HasPrefix(region, prefix) {
+			return partition
+		}
+	}
+
+	return "aws"
+}
+
+func parse(s string, service string) (arn.ARN, []string, error) {
+	parsed, err := arn.Parse(s)
+	if err != nil {
+		return arn.ARN{}, nil, fmt.Errorf("%q is not a valid ARN: %s", s, err)
+	}
+
+	if _, ok := Partitions[parsed.Partition]; !ok {
+		return arn.ARN{}, nil, fmt.Errorf("%q has an unsupported partition %q", s, parsed.Partition)
+	}
+
+	if parsed.Service != service {
+		return arn.ARN{}, nil, fmt.Errorf("%q is not a %s ARN", s, service)
+	}
+
+	if !regionRegexp.MatchString(parsed.Region) || RegionPartition(parsed.Region) != parsed.Partition {
+		return arn.ARN{}, nil, fmt.Errorf("%q has an invalid region %q for partition %q", s, parsed.Region, parsed.Partition)
+	}
+
+	if !accountIDRegexp.MatchString(parsed.AccountID) {
+		return arn.ARN{}, nil, fmt.Errorf("%q has an invalid account ID %q", s, parsed.AccountID)
+	}
+
+	return parsed, strings.Split(parsed.Resource, ":"), nil
+}
+
+func newLayer(s string, parsed arn.ARN, name string) (Layer, error) {
+	if !layerNameRegexp.MatchString(name) {
+		return Layer{}, fmt.Errorf("%q has an invalid layer name %q", s, name)
+	}
+
+	return Layer{
+		Partition: parsed.Partition,
+		Region:    parsed.Region,
+		AccountID: parsed.AccountID,
+		Name:      name,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package arns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLayer(t *testing.T) {
+	layer, err := ParseLayer("arn:aws-cn:lambda:cn-north-1:111111111111:layer:example-layer")
+	assert.NoError(t, err)
+	assert.Equal(t, Layer{Partition: "aws-cn", Region: "cn-north-1", AccountID: "111111111111", Name: "example-layer"}, layer)
+	assert.Equal(t, "arn:aws-cn:lambda:cn-north-1:111111111111:layer:example-layer", layer.String())
+
+	for _, s := range []string{
+		"example-layer",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example-layer:3",
+		"arn:aws:lambda:us-east-1:111111111111:function:example",
+		"arn:aws:lambda:us-east-1:1111:layer:example-layer",
+		"arn:aws-cn:lambda:us-east-1:111111111111:layer:example-layer",
+		"arn:aws-iso:lambda:us-iso-east-1:111111111111:layer:example-layer",
+		"arn:aws:secretsmanager:us-east-1:111111111111:layer:example-layer",
+	} {
+		_, err := ParseLayer(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseLayerVersion(t *testing.T) {
+	layerVersion, err := ParseLayerVersion("arn:aws-us-gov:lambda:us-gov-west-1:111111111111:layer:example-layer:3")
+	assert.NoError(t, err)
+	assert.Equal(t, "example-layer", layerVersion.Name)
+	assert.Equal(t, int64(3), layerVersion.Version)
+	assert.Equal(t, "arn:aws-us-gov:lambda:us-gov-west-1:111111111111:layer:example-layer", layerVersion.Layer.String())
+	assert.Equal(t, "arn:aws-us-gov:lambda:us-gov-west-1:111111111111:layer:example-layer:3", layerVersion.String())
+
+	for _, s := range []string{
+		"arn:aws:lambda:us-east-1:111111111111:layer:example-layer",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example-layer:latest",
+		"arn:aws:lambda:us-east-1:111111111111:layer:example-layer:0",
+	} {
+		_, err := ParseLayerVersion(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseSecret(t *testing.T) {
+	secret, err := ParseSecret("arn:aws:secretsmanager:us-east-1:111111111111:secret:example1/env-1/123")
+	assert.NoError(t, err)
+	assert.Equal(t, "example1/env-1/123", secret.Name)
+	assert.Equal(t, "arn:aws:secretsmanager:eu-central-1:111111111111:secret:example1/env-1/123", secret.InRegion("eu-central-1").String())
+	assert.True(t, secret.IsPartial())
+
+	secret, err = ParseSecret("arn:aws:secretsmanager:us-east-1:111111111111:secret:example1/env-1/123-AbCdEf")
+	assert.NoError(t, err)
+	assert.False(t, secret.IsPartial())
+
+	for _, s := range []string{
+		"example1/env-1/123",
+		"arn:aws:secretsmanager::111111111111:secret:example1/env-1/123",
+		"arn:aws:secretsmanager:us-east-1:111111111111:example1/env-1/123",
+		"arn:aws:ssm:us-east-1:111111111111:secret:example1/env-1/123",
+		"arn:aws:secretsmanager:cn-north-1:111111111111:secret:example1/env-1/123",
+	} {
+		_, err := ParseSecret(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRegionPartition(t *testing.T) {
+	assert.Equal(t, "aws", RegionPartition("eu-central-1"))
+	assert.Equal(t, "aws-cn", RegionPartition("cn-northwest-1"))
+	assert.Equal(t, "aws-us-gov", RegionPartition("us-gov-east-1"))
+}
@@ -1,21 +1,34 @@
 package main
 
 import (
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
+	"context"
+	"flag"
+	"log"
 
-	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/awsenvsecretlayer"
-	// "terraform-provider-awsenvsecretlayer/awsenvsecretlayer"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5/tf5server"
 
+	"github.com/saltydogtechnology/terraform-provider-awsenvsecretlayer/awsenvsecretlayer"
 )
 
 // Generate the Terraform provider documentation using `tfplugindocs`:
 //go:generate go run github.com/hashicorp/terraform-plugin-docs/cmd/tfplugindocs
 
 func main() {
-	plugin.Serve(&plugin.ServeOpts{
-		ProviderFunc: func() *schema.Provider {
-			return awsenvsecretlayer.Provider()
-		},
-	})
+	debug := flag.Bool("debug", false, "start the provider in debug mode for debuggers such as delve")
+	flag.Parse()
+
+	serverFactory, err := awsenvsecretlayer.ProtoV5ProviderServerFactory(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var serveOpts []tf5server.ServeOpt
+	if *debug {
+		serveOpts = append(serveOpts, tf5server.WithManagedDebug())
+	}
+
+	err = tf5server.Serve("registry.terraform.io/saltydogtechnology/awsenvsecretlayer", serverFactory, serveOpts...)
+	if err != nil {
+		log.Fatal(err)
+	}
 }